@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// AccessCount returns the number of reads of the current value, always zero without access tracking
+func (t *cacheStore) AccessCount(key []byte) (int64, bool) {
+	e, ok := t.getEntry(string(t.nsKey(key)))
+	if !ok {
+		return 0, false
+	}
+	return atomic.LoadInt64(&e.hits), true
+}
+
+// HotKeys returns up to n most read keys in descending order of reads, O(n log n) scan for diagnostics
+func (t *cacheStore) HotKeys(n int) [][]byte {
+	if n <= 0 {
+		return nil
+	}
+
+	type hotKey struct {
+		key  string
+		hits int64
+	}
+
+	var list []hotKey
+	for key, item := range t.items() {
+		if e, ok := toEntry(item.Object); ok {
+			if hits := atomic.LoadInt64(&e.hits); hits > 0 {
+				list = append(list, hotKey{key, hits})
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].hits != list[j].hits {
+			return list[i].hits > list[j].hits
+		}
+		return list[i].key < list[j].key
+	})
+
+	if len(list) > n {
+		list = list[:n]
+	}
+	keys := make([][]byte, len(list))
+	for i, h := range list {
+		keys[i] = []byte(t.userKey(h.key))
+	}
+	return keys
+}
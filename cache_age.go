@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import "time"
+
+// OldestEntry returns the key and creation time of the oldest live entry, O(n) scan for diagnostics
+func (t *cacheStore) OldestEntry() ([]byte, time.Time, bool) {
+	return t.entryByCreation(func(created, best int64) bool {
+		return created < best
+	})
+}
+
+// NewestEntry returns the key and creation time of the most recently written live entry, O(n) scan for diagnostics
+func (t *cacheStore) NewestEntry() ([]byte, time.Time, bool) {
+	return t.entryByCreation(func(created, best int64) bool {
+		return created > best
+	})
+}
+
+// entries without creation time are skipped
+func (t *cacheStore) entryByCreation(better func(created, best int64) bool) ([]byte, time.Time, bool) {
+	var (
+		bestKey string
+		best    int64
+		found   bool
+	)
+	for key, item := range t.items() {
+		e, ok := toEntry(item.Object)
+		if !ok || e.Created == 0 {
+			continue
+		}
+		if !found || better(e.Created, best) {
+			bestKey, best, found = key, e.Created, true
+		}
+	}
+	if !found {
+		return nil, time.Time{}, false
+	}
+	return []byte(t.userKey(bestKey)), time.Unix(0, best), true
+}
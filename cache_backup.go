@@ -0,0 +1,330 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// the framed backup starts with the magic followed by the format version byte,
+// version 1 records carry key, value and ttl, version 2 adds content type and creation time
+const (
+	backupMagic   = "CSBK"
+	backupVersion = 2
+	// limit of the key, value and content type length in a record
+	maxBackupField = 1 << 30
+)
+
+// StreamBackup writes live entries in the framed format, where each record stores
+// the remaining ttl in seconds at backup time instead of the absolute expiration,
+// so the backup stays portable across machines and time.
+//
+// The stream starts with the header "CSBK" and the format version byte, then records follow.
+// Record layout: uvarint(len(key)) key uvarint(len(value)) value uvarint(ttlSeconds)
+// uvarint(len(contentType)) contentType uvarint(created) crc32, ttlSeconds is zero for entries without expiration,
+// created is in unix nanoseconds, crc32 is big endian IEEE of the preceding fields.
+// StreamRestore also reads version 1 streams, which have no contentType and created fields.
+func (t *cacheStore) StreamBackup(w io.Writer) error {
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	now := time.Now().UnixNano()
+
+	if _, err := bw.WriteString(backupMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(backupVersion); err != nil {
+		return err
+	}
+
+	for key, item := range t.items() {
+
+		e, ok := toEntry(item.Object)
+		if !ok {
+			continue
+		}
+
+		rec := &backupRecord{
+			key:         []byte(t.userKey(key)),
+			value:       e.Value,
+			ttlSeconds:  remainingSeconds(item.Expiration, now),
+			contentType: e.ContentType,
+			created:     e.Created,
+		}
+		if err := writeRecord(bw, rec); err != nil {
+			return err
+		}
+
+	}
+
+	return bw.Flush()
+}
+
+// RestoreResult describes the outcome of StreamRestoreResult
+type RestoreResult struct {
+	// records stored in the cache
+	Restored int
+	// corrupt or truncated records
+	Skipped int
+	// valid records the store refused, for example because of the key length
+	Errored int
+	Errors  []error
+}
+
+// StreamRestore reads entries written by StreamBackup and recomputes their expiration
+// relative to the restore time, entries without expiration are restored with NeverExpire,
+// existing entries with the same keys are overwritten. The first bad record aborts the restore.
+func (t *cacheStore) StreamRestore(src io.Reader) error {
+	_, err := t.StreamRestoreResult(src, false)
+	return err
+}
+
+// StreamRestoreResult is StreamRestore that either aborts on the first bad record or skips it and goes on
+// collecting errors, a record is stored only after its checksum is verified so no entry is half-written,
+// only records failing ErrCorruptRecord are skipped, a truncated stream or a read error always ends the restore with the error
+func (t *cacheStore) StreamRestoreResult(src io.Reader, skipCorrupt bool) (*RestoreResult, error) {
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	br := bufio.NewReader(src)
+	ctx := context.Background()
+	result := &RestoreResult{}
+
+	version, err := readBackupHeader(br)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		rec, err := readRecord(br, version)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, err)
+			if !skipCorrupt || err != ErrCorruptRecord {
+				return result, err
+			}
+			continue
+		}
+		ttlSeconds := rec.ttlSeconds
+		if ttlSeconds == 0 {
+			ttlSeconds = NeverExpire
+		}
+		if err := t.setRawMeta(ctx, rec.key, rec.value, rec.contentType, rec.created, ttlSeconds); err != nil {
+			result.Errored++
+			result.Errors = append(result.Errors, fmt.Errorf("key '%s', %w", rec.key, err))
+			if skipCorrupt {
+				continue
+			}
+			return result, err
+		}
+		result.Restored++
+	}
+
+}
+
+// RestoreWithTTL loads the backup written by Backup like Restore but applies ttlSeconds to every entry
+// instead of the stored expiration, so entries expired in the backup come back too, live keys are kept
+func (t *cacheStore) RestoreWithTTL(src io.Reader, ttlSeconds int) error {
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	items := map[string]cache.Item{}
+	if err := gob.NewDecoder(src).Decode(&items); err != nil {
+		return err
+	}
+
+	ttl := t.expiration(ttlSeconds)
+	for key, item := range items {
+		e, ok := toEntry(item.Object)
+		if !ok {
+			continue
+		}
+		unlock := t.locks.lockKey([]byte(key))
+		if _, found := t.cache.Get(key); !found {
+			t.setItem(key, e, ttl)
+			t.advanceVersion(e.Version)
+		}
+		unlock()
+	}
+
+	return nil
+}
+
+// saveItems is the equivalent of go-cache Save working on the snapshot
+func saveItems(w io.Writer, items map[string]cache.Item) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("error registering item types with gob library, %v", x)
+		}
+	}()
+	for _, item := range items {
+		gob.Register(item.Object)
+	}
+	return gob.NewEncoder(w).Encode(&items)
+}
+
+// remaining ttl in seconds rounded up, zero for no expiration
+func remainingSeconds(expiration, now int64) int {
+	if expiration <= 0 {
+		return 0
+	}
+	left := expiration - now
+	if left <= 0 {
+		return 1
+	}
+	return int((left + int64(time.Second) - 1) / int64(time.Second))
+}
+
+type backupRecord struct {
+	key         []byte
+	value       []byte
+	ttlSeconds  int
+	contentType string
+	created     int64
+}
+
+func writeRecord(w *bufio.Writer, r *backupRecord) error {
+	data := appendRecord(nil, r, backupVersion)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(data))
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// appendRecord appends the canonical encoding of the record fields in the layout of the version
+func appendRecord(buf []byte, r *backupRecord, version byte) []byte {
+	buf = appendUvarint(buf, uint64(len(r.key)))
+	buf = append(buf, r.key...)
+	buf = appendUvarint(buf, uint64(len(r.value)))
+	buf = append(buf, r.value...)
+	buf = appendUvarint(buf, uint64(r.ttlSeconds))
+	if version >= 2 {
+		buf = appendUvarint(buf, uint64(len(r.contentType)))
+		buf = append(buf, r.contentType...)
+		buf = appendUvarint(buf, uint64(r.created))
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+// recordChecksum covers the canonical encoding of the record fields
+func recordChecksum(r *backupRecord, version byte) uint32 {
+	return crc32.ChecksumIEEE(appendRecord(nil, r, version))
+}
+
+// readBackupHeader returns the format version, ErrUnsupportedBackupVersion for streams of other formats or newer versions
+func readBackupHeader(r *bufio.Reader) (byte, error) {
+	var header [len(backupMagic) + 1]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, ErrUnsupportedBackupVersion
+		}
+		return 0, err
+	}
+	version := header[len(backupMagic)]
+	if string(header[:len(backupMagic)]) != backupMagic || !supportedBackupVersion(int(version)) {
+		return 0, ErrUnsupportedBackupVersion
+	}
+	return version, nil
+}
+
+func supportedBackupVersion(version int) bool {
+	return version >= 1 && version <= backupVersion
+}
+
+func readRecord(r *bufio.Reader, version byte) (*backupRecord, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	rec := &backupRecord{}
+	if rec.key, err = readBytes(r, n); err != nil {
+		return nil, err
+	}
+	if rec.value, err = readUvarintBytes(r); err != nil {
+		return nil, err
+	}
+	if n, err = binary.ReadUvarint(r); err != nil {
+		return nil, noEOF(err)
+	}
+	rec.ttlSeconds = int(n)
+	if version >= 2 {
+		contentType, err := readUvarintBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		rec.contentType = string(contentType)
+		if n, err = binary.ReadUvarint(r); err != nil {
+			return nil, noEOF(err)
+		}
+		rec.created = int64(n)
+	}
+	var sum [4]byte
+	if _, err = io.ReadFull(r, sum[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != recordChecksum(rec, version) {
+		return nil, ErrCorruptRecord
+	}
+	return rec, nil
+}
+
+func readUvarintBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	return readBytes(r, n)
+}
+
+// readBytes fails with ErrCorruptRecord for lengths above maxBackupField, smaller ones are read as the data arrives,
+// so a corrupt length costs at most the rest of the stream in memory
+func readBytes(r *bufio.Reader, n uint64) ([]byte, error) {
+	if n > maxBackupField {
+		return nil, ErrCorruptRecord
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, noEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// EOF in the middle of a record means truncated stream
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
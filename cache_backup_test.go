@@ -0,0 +1,243 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestoreCorruptLength(t *testing.T) {
+
+	for _, skipCorrupt := range []bool{false, true} {
+
+		var buf bytes.Buffer
+		buf.WriteString(backupMagic)
+		buf.WriteByte(backupVersion)
+		var tmp [binary.MaxVarintLen64]byte
+		buf.Write(tmp[:binary.PutUvarint(tmp[:], 1<<62)])
+		buf.WriteString("garbage")
+
+		s := New("test")
+		result, err := s.StreamRestoreResult(&buf, skipCorrupt)
+		s.Destroy()
+
+		if !skipCorrupt && err != ErrCorruptRecord {
+			t.Fatalf("got %v, want ErrCorruptRecord", err)
+		}
+		if result.Restored != 0 || result.Skipped == 0 {
+			t.Fatalf("skipCorrupt %v: unexpected result %+v", skipCorrupt, result)
+		}
+	}
+}
+
+func TestBackupConcurrentSetRaw(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	ctx := context.Background()
+
+	var writers sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 8; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := []byte(fmt.Sprintf("w%d/%d", w, i%64))
+				s.SetRaw(ctx, key, key, 60)
+			}
+		}(w)
+	}
+
+	var backups sync.WaitGroup
+	for b := 0; b < 4; b++ {
+		backups.Add(1)
+		go func(stream bool) {
+			defer backups.Done()
+			for i := 0; i < 5; i++ {
+				var buf bytes.Buffer
+				dst := New("restore")
+				var err error
+				if stream {
+					if err = s.StreamBackup(&buf); err == nil {
+						var result *RestoreResult
+						result, err = dst.StreamRestoreResult(&buf, false)
+						if err == nil && result.Skipped != 0 {
+							err = fmt.Errorf("skipped %d records", result.Skipped)
+						}
+					}
+				} else {
+					if _, err = s.Backup(&buf, 0); err == nil {
+						err = dst.Restore(&buf)
+					}
+				}
+				dst.Destroy()
+				if err != nil {
+					t.Errorf("stream %v: %v", stream, err)
+					return
+				}
+			}
+		}(b%2 == 0)
+	}
+	backups.Wait()
+	close(stop)
+	writers.Wait()
+}
+
+// blockingReader serves the first n bytes, then reports on blocked and waits for release before the rest
+type blockingReader struct {
+	data     []byte
+	n        int
+	blocked  chan struct{}
+	released chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		close(r.blocked)
+		<-r.released
+		r.n = -1
+	}
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	if r.n > 0 && len(p) > r.n {
+		p = p[:r.n]
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if r.n > 0 {
+		r.n -= n
+	}
+	return n, nil
+}
+
+func TestRestoresWithPendingFreeze(t *testing.T) {
+
+	src := New("src")
+	defer src.Destroy()
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("k%d", i))
+		src.SetRaw(ctx, key, key, 60)
+	}
+	var gobBackup, streamBackup bytes.Buffer
+	if _, err := src.Backup(&gobBackup, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.StreamBackup(&streamBackup); err != nil {
+		t.Fatal(err)
+	}
+
+	restores := map[string]func(s *cacheStore) error{
+		"Restore": func(s *cacheStore) error {
+			return s.Restore(bytes.NewReader(gobBackup.Bytes()))
+		},
+		"RestoreWithTTL": func(s *cacheStore) error {
+			return s.RestoreWithTTL(bytes.NewReader(gobBackup.Bytes()), 60)
+		},
+	}
+
+	for name, restore := range restores {
+		restore := restore
+		t.Run(name, func(t *testing.T) {
+			s := New("test")
+			defer s.Destroy()
+
+			// the stream restore holds the backup lock between records while the other restore
+			// and Freeze line up behind it
+			r := &blockingReader{data: streamBackup.Bytes(), n: streamBackup.Len() / 2,
+				blocked: make(chan struct{}), released: make(chan struct{})}
+			errs := make(chan error, 2)
+			go func() { errs <- s.StreamRestore(r) }()
+			<-r.blocked
+			go func() { errs <- restore(s) }()
+			time.Sleep(20 * time.Millisecond)
+			frozen := make(chan struct{})
+			go func() {
+				s.Freeze()
+				close(frozen)
+			}()
+			time.Sleep(20 * time.Millisecond)
+			close(r.released)
+
+			select {
+			case <-frozen:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Freeze deadlocked with the restores")
+			}
+			s.Unfreeze()
+			for i := 0; i < 2; i++ {
+				select {
+				case err := <-errs:
+					if err != nil {
+						t.Fatalf("restore: %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("restores deadlocked")
+				}
+			}
+		})
+	}
+}
+
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestStreamRestoreSkipReportsReadErrors(t *testing.T) {
+
+	src := New("src")
+	defer src.Destroy()
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("k%d", i))
+		src.SetRaw(ctx, key, key, 60)
+	}
+	var backup bytes.Buffer
+	if err := src.StreamBackup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	errNetwork := errors.New("connection reset")
+
+	cases := map[string]io.Reader{
+		"truncated":  bytes.NewReader(backup.Bytes()[:backup.Len()-3]),
+		"read error": &failingReader{bytes.NewReader(backup.Bytes()[:backup.Len()/2]), errNetwork},
+	}
+	for name, r := range cases {
+		s := New("test")
+		_, err := s.StreamRestoreResult(r, true)
+		s.Destroy()
+		if err == nil || err == ErrCorruptRecord {
+			t.Errorf("%s: got %v, want the read error", name, err)
+		}
+		if name == "read error" && !errors.Is(err, errNetwork) {
+			t.Errorf("%s: got %v, want %v", name, err, errNetwork)
+		}
+	}
+}
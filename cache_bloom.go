@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"github.com/patrickmn/go-cache"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// number of counters touched per key prefix
+const bloomHashes = 4
+
+// prefixBloom is the counting bloom filter of key prefixes of the fixed length, counters make deletes possible,
+// a counter left too high only costs a false positive, so races between writers and the sweep never cause false negatives
+type prefixBloom struct {
+	prefixLen int
+	counters  []uint32
+}
+
+func (t *prefixBloom) enabled() bool {
+	return len(t.counters) > 0
+}
+
+// slots derives the counters of the prefix by double hashing
+func (t *prefixBloom) slots(prefix string) [bloomHashes]int {
+	h := fnv.New64a()
+	h.Write([]byte(prefix))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+	var slots [bloomHashes]int
+	for i := range slots {
+		slots[i] = int((h1 + uint32(i)*h2) % uint32(len(t.counters)))
+	}
+	return slots
+}
+
+// keys shorter than the prefix length are tracked under the whole key
+func (t *prefixBloom) prefixOf(key string) string {
+	if len(key) > t.prefixLen {
+		return key[:t.prefixLen]
+	}
+	return key
+}
+
+func (t *prefixBloom) add(key string) {
+	for _, i := range t.slots(t.prefixOf(key)) {
+		atomic.AddUint32(&t.counters[i], 1)
+	}
+}
+
+func (t *prefixBloom) remove(key string) {
+	for _, i := range t.slots(t.prefixOf(key)) {
+		for {
+			n := atomic.LoadUint32(&t.counters[i])
+			if n == 0 || atomic.CompareAndSwapUint32(&t.counters[i], n, n-1) {
+				break
+			}
+		}
+	}
+}
+
+// mayContain is false only if no key with the prefix was added, prefixes shorter than the prefix length always pass
+func (t *prefixBloom) mayContain(prefix string) bool {
+	if len(prefix) < t.prefixLen {
+		return true
+	}
+	for _, i := range t.slots(prefix[:t.prefixLen]) {
+		if atomic.LoadUint32(&t.counters[i]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *prefixBloom) reset() {
+	for i := range t.counters {
+		atomic.StoreUint32(&t.counters[i], 0)
+	}
+}
+
+// bloomAdded is called under the key lock before the key is set, keys already live are counted once
+func (t *cacheStore) bloomAdded(key string) {
+	if !t.bloom.enabled() {
+		return
+	}
+	if _, ok := t.cache.Get(key); !ok {
+		t.bloom.add(t.userKey(key))
+	}
+}
+
+// bloomRebuild recounts the live items after the cache was loaded or flushed
+func (t *cacheStore) bloomRebuild(items map[string]cache.Item) {
+	t.bloom.reset()
+	for key := range items {
+		t.bloom.add(t.userKey(key))
+	}
+}
+
+// skipPrefix reports whether enumeration of the prefix can return right away
+func (t *cacheStore) skipPrefix(prefix []byte) bool {
+	return t.bloom.enabled() && !t.bloom.mayContain(string(prefix))
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"encoding/binary"
+	"github.com/keyvalstore/store"
+	"hash/fnv"
+)
+
+// Checksum returns the order-independent hash of all live entries under the prefix,
+// two stores with identical contents produce the same checksum regardless of the map ordering
+func (t *cacheStore) Checksum(prefix []byte) (uint64, error) {
+
+	var sum uint64
+	var buf [binary.MaxVarintLen64]byte
+
+	err := t.doEnumerateRaw(prefix, nil, 0, false, func(entry *store.RawEntry) bool {
+		h := fnv.New64a()
+		n := binary.PutUvarint(buf[:], uint64(len(entry.Key)))
+		h.Write(buf[:n])
+		h.Write(entry.Key)
+		h.Write(entry.Value)
+		sum ^= h.Sum64()
+		return true
+	})
+
+	return sum, err
+}
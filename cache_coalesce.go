@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// coalescer keeps the writes of SetRaw waiting for the end of the window,
+// lock order is key lock first and then the coalescer mutex
+type coalescer struct {
+	mu      sync.Mutex
+	pending map[string]*pendingWrite
+}
+
+type pendingWrite struct {
+	e          *entry
+	ttlSeconds int
+	timer      *time.Timer
+}
+
+func (t *cacheStore) coalescing() bool {
+	return t.conf.WriteCoalescing > 0
+}
+
+// coalesceLocked is called under the key lock, the first write of the key starts the window and later ones replace the value
+func (t *cacheStore) coalesceLocked(key []byte, value []byte, ttlSeconds int) {
+	e := t.newEntry(value)
+	k := string(key)
+	t.coalesce.mu.Lock()
+	defer t.coalesce.mu.Unlock()
+	if p, ok := t.coalesce.pending[k]; ok {
+		p.e, p.ttlSeconds = e, ttlSeconds
+		return
+	}
+	if t.coalesce.pending == nil {
+		t.coalesce.pending = make(map[string]*pendingWrite)
+	}
+	t.coalesce.pending[k] = &pendingWrite{
+		e:          e,
+		ttlSeconds: ttlSeconds,
+		timer:      time.AfterFunc(t.conf.WriteCoalescing, func() { t.flushPending(k) }),
+	}
+}
+
+// pendingEntry returns the latest value waiting to be written, so reads observe it before the flush
+func (t *cacheStore) pendingEntry(key string) (*entry, bool) {
+	t.coalesce.mu.Lock()
+	defer t.coalesce.mu.Unlock()
+	if p, ok := t.coalesce.pending[key]; ok {
+		return p.e, true
+	}
+	return nil, false
+}
+
+func (t *cacheStore) takePending(key string) (*pendingWrite, bool) {
+	t.coalesce.mu.Lock()
+	defer t.coalesce.mu.Unlock()
+	p, ok := t.coalesce.pending[key]
+	if ok {
+		p.timer.Stop()
+		delete(t.coalesce.pending, key)
+	}
+	return p, ok
+}
+
+// settleLocked writes the pending value of the key right away, it is called under the key lock
+// before any other operation on the key so the operation sees the value and the flush does not overwrite its result
+func (t *cacheStore) settleLocked(key []byte) {
+	if p, ok := t.takePending(string(key)); ok {
+		t.putLocked(key, p.e, p.ttlSeconds)
+	}
+}
+
+// flushPending runs at the end of the window, a frozen store keeps the write pending for one more window
+func (t *cacheStore) flushPending(key string) {
+
+	if err := t.enterWrite(); err != nil {
+		t.coalesce.mu.Lock()
+		if p, ok := t.coalesce.pending[key]; ok {
+			p.timer.Reset(t.conf.WriteCoalescing)
+		}
+		t.coalesce.mu.Unlock()
+		return
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow([]byte(key))
+
+	unlock := t.locks.lockKey([]byte(key))
+	defer unlock()
+
+	t.settleLocked([]byte(key))
+}
+
+// pendingKeys returns the keys with a coalesced write waiting
+func (t *cacheStore) pendingKeys() []string {
+	t.coalesce.mu.Lock()
+	defer t.coalesce.mu.Unlock()
+	keys := make([]string, 0, len(t.coalesce.pending))
+	for key := range t.coalesce.pending {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// FlushPending writes all coalesced writes without waiting for their windows
+func (t *cacheStore) FlushPending() {
+
+	for _, key := range t.pendingKeys() {
+		unlock := t.locks.lockKey([]byte(key))
+		t.settleLocked([]byte(key))
+		unlock()
+		t.evictOverflow([]byte(key))
+	}
+}
+
+// discardPending drops the coalesced writes of the matching keys, used by the drop operations
+func (t *cacheStore) discardPending(match func(key string) bool) {
+	t.coalesce.mu.Lock()
+	defer t.coalesce.mu.Unlock()
+	for key, p := range t.coalesce.pending {
+		if match(key) {
+			p.timer.Stop()
+			delete(t.coalesce.pending, key)
+		}
+	}
+}
+
+// lockKeyContext takes the key lock for the operation and settles the pending write of the key first
+func (t *cacheStore) lockKeyContext(ctx context.Context, key []byte) (func(), error) {
+	unlock, err := t.locks.lockKeyContext(ctx, key)
+	if err == nil && t.coalescing() {
+		t.settleLocked(key)
+	}
+	return unlock, err
+}
+
+// lockKeysContext is lockKeyContext for the operations over several keys
+func (t *cacheStore) lockKeysContext(ctx context.Context, keys [][]byte) (func(), error) {
+	unlock, err := t.locks.lockKeysContext(ctx, keys)
+	if err == nil && t.coalescing() {
+		for _, key := range keys {
+			t.settleLocked(key)
+		}
+	}
+	return unlock, err
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Codec converts objects to values for SetObject and GetObject
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// SetObject encodes the object by the configured codec and stores it
+func (t *cacheStore) SetObject(ctx context.Context, key []byte, obj interface{}, ttlSeconds int) error {
+	if t.conf.Codec == nil {
+		return ErrNoCodec
+	}
+	value, err := t.conf.Codec.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return t.SetRaw(ctx, key, value, ttlSeconds)
+}
+
+// GetObject decodes the stored value into dest, a typed pointer, returns os.ErrNotExist if absent
+func (t *cacheStore) GetObject(ctx context.Context, key []byte, dest interface{}) error {
+	if t.conf.Codec == nil {
+		return ErrNoCodec
+	}
+	value, err := t.GetRaw(ctx, key, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	return t.conf.Codec.Unmarshal(value, dest)
+}
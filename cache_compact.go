@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import "time"
+
+func (t *cacheStore) runCompactor(interval time.Duration, discardRatio float64) {
+	t.compactor.start(interval, func() {
+		t.compactCycle(discardRatio)
+	})
+}
+
+// compactCycle sweeps only when the share of expired entries in the cache reaches discardRatio
+func (t *cacheStore) compactCycle(discardRatio float64) {
+
+	total := t.cache.ItemCount()
+	if total == 0 {
+		t.stats.compacted(0)
+		return
+	}
+
+	if expired := total - len(t.cache.Items()); float64(expired) < discardRatio*float64(total) {
+		t.stats.compacted(0)
+		return
+	}
+
+	t.SweepExpired()
+
+	reclaimed := total - t.cache.ItemCount()
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	t.stats.compacted(int64(reclaimed))
+}
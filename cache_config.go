@@ -7,16 +7,117 @@ package cachestore
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"github.com/keyvalstore/store"
 	"time"
 )
 
 var (
 	ErrCanceled         = errors.New("operation was canceled")
+	ErrCacheFull        = errors.New("cache is full")
+	ErrInvalidWindow    = errors.New("window must be positive")
+	ErrCallbackPanic    = errors.New("callback panic")
+	ErrFrozen           = errors.New("store is frozen")
+	ErrKeyTooLong       = errors.New("key is too long")
+	ErrEmptyKey         = errors.New("key is empty")
+	// returned by GetRaw for the required key reaped by expiration within the grace period, wraps os.ErrNotExist
+	ErrExpired          = fmt.Errorf("entry has expired, %w", os.ErrNotExist)
+	ErrVersionConflict  = errors.New("version conflict")
+	ErrValueTooLarge    = errors.New("value is too large")
+	ErrCorruptRecord    = errors.New("corrupt backup record")
+	ErrNoCodec          = errors.New("codec is not configured")
+	ErrRetriesExhausted = errors.New("compare and set retries exhausted")
+	// returned by StreamRestore and ImportTar for backups of another format version
+	ErrUnsupportedBackupVersion = errors.New("unsupported backup version")
+	ErrInvalidTiers     = errors.New("fresh ttl must be positive and max stale ttl not negative")
+	ErrInconsistent     = errors.New("store is inconsistent")
+	ErrEnumerationTimeout = errors.New("enumeration exceeded the maximum duration")
+)
+
+// ttlSeconds sentinels accepted by every raw write and touch operation, positive values are seconds
+const (
+	// applies DefaultTTL, or no expiration when DefaultTTL is not set, same as store.NoTTL
+	UseDefaultTTL = store.NoTTL
+	// keeps the entry without expiration regardless of DefaultTTL, MaxTTL still caps it
+	NeverExpire = -1
 )
 
 type Config struct {
+	// passed to go-cache and only used by writes made directly on the underlying cache with cache.DefaultExpiration,
+	// raw operations of the store always pass the explicit expiration and are governed by DefaultTTL
 	DefaultExpiration time.Duration
 	CleanupInterval   time.Duration
+	// used by raw operations given UseDefaultTTL, zero keeps such entries without expiration
+	DefaultTTL        time.Duration
+	PinnedPrefixes    []string
+	MaxTTL            time.Duration
+	MinTTL            time.Duration
+	// zero means no limit on the number of entries
+	MaxEntries        int
+	// receives entries evicted by MaxEntries instead of dropping them
+	Spillover         store.DataStore
+	// consulted before an expired entry is reaped, keep re-arms the entry with newTTL (<= 0 means no expiration)
+	ExpirationPolicy  func(key, value []byte) (keep bool, newTTL time.Duration)
+	// user callback panics are returned as ErrCallbackPanic errors instead of being re-raised
+	RecoverPanics     bool
+	// mutating operations on the frozen store return ErrFrozen instead of waiting for Unfreeze
+	FailWhenFrozen    bool
+	// zero means no limit on the key length in bytes
+	MaxKeyLength      int
+	// selects victims of MaxEntries eviction, LRU and LFU enable access tracking on reads
+	EvictionPolicy    EvictionPolicy
+	// keeps tombstones of expired keys so GetRaw can return ErrExpired, zero disables
+	ExpiredGrace      time.Duration
+	// merges the current and incoming values on MergeRaw version conflict, nil result rejects the merge
+	ConflictResolver  func(key, current, incoming []byte) []byte
+	// limits the value read by SetRawStream, zero means unlimited
+	MaxStreamSize     int64
+	// counts reads per entry for AccessCount and HotKeys
+	AccessTracking    bool
+	// tracks value sizes for Stats
+	ValueSizeStats    bool
+	// expiration times are rounded up to a multiple of it since the unix epoch
+	TTLRounding       time.Duration
+	// sweeps through the heap of expirations instead of scanning all entries
+	ExpirationIndex   bool
+	// used by SetObject and GetObject
+	Codec             Codec
+	// enumeration skips values put into the cache directly that are not []byte instead of surfacing them
+	StrictByteValues  bool
+	// prepended to every key in the cache, so stores sharing one cache via FromCache do not collide
+	Namespace         string
+	// number of recent operations kept for RecentOps, zero disables
+	OperationLog      int
+	// keeps markers of removed keys for EnumerateSince, zero disables
+	SoftDeleteGrace   time.Duration
+	// checks every value before it is written, the error aborts the write
+	ValueValidator    func(key, value []byte) error
+	// runs the background compaction every interval, zero disables
+	AutoCompactInterval time.Duration
+	// share of expired entries in the cache needed for the compaction cycle to sweep
+	AutoCompactRatio    float64
+	// length of the key prefixes tracked by the bloom filter and the number of its counters, zero counters disables
+	PrefixBloomLen      int
+	PrefixBloomCounters int
+	// the store is registered by name on creation and unregistered by Destroy and Close
+	Registry            *Registry
+	// entries not accessed for it are evicted by the background sweep, zero disables
+	IdleTimeout         time.Duration
+	// SetRaw of the same key within the window is collapsed into one write at its end, zero disables
+	WriteCoalescing     time.Duration
+	// the oldest entries are evicted while the heap is above it, zero disables
+	TargetHeapMB        uint64
+	// raw operations with nil or empty key fail with ErrEmptyKey instead of using the empty key
+	RejectEmptyKeys     bool
+	// Verify runs in the background every interval and logs the mismatch found, zero disables
+	VerifyInterval      time.Duration
+	// enumerations running longer stop with ErrEnumerationTimeout, zero disables
+	MaxEnumerationDuration time.Duration
+	// victims of MaxEntries eviction are picked among this many sampled entries, zero or less ranks all entries
+	EvictionSampleSize  int
+	// content type reported for entries written without one
+	DefaultContentType  string
 }
 
 // Option configures memory storage using the functional options paradigm
@@ -53,4 +154,278 @@ func WithCleanupInterval(value time.Duration) Option {
 	})
 }
 
+// keys under the pinned prefix survive DropAll, DropWithPrefix still removes them explicitly
+func WithPinnedPrefix(prefix string) Option {
+	return optionFunc(func(opts *Config) {
+		opts.PinnedPrefixes = append(opts.PinnedPrefixes, prefix)
+	})
+}
+
+// clamps any requested ttl, including no expiration, down to the maximum
+func WithMaxTTL(value time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MaxTTL = value
+	})
+}
+
+// raises any positive requested ttl up to the minimum, no expiration is not affected
+func WithMinTTL(value time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MinTTL = value
+	})
+}
+
+// ttl applied by raw operations when the caller does not give one, independent of DefaultExpiration
+func WithDefaultTTL(value time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.DefaultTTL = value
+	})
+}
+
+// caps the number of entries, on overflow expired entries are removed first and then the ones selected by the eviction policy
+func WithMaxEntries(value int) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MaxEntries = value
+	})
+}
+
+// writes entries evicted by WithMaxEntries to the destination store with their remaining ttl,
+// GetRaw on a miss checks the destination and promotes the entry back into the cache.
+// Every miss costs a read of the destination store, spillover failures are logged and counted but never fatal.
+func WithSpillover(dst store.DataStore) Option {
+	return optionFunc(func(opts *Config) {
+		opts.Spillover = dst
+	})
+}
+
+// expiration policy can veto reaping of the expired entry and extend it instead,
+// explicit deletes and evictions made by the store never consult the policy
+func WithExpirationPolicy(policy func(key, value []byte) (keep bool, newTTL time.Duration)) Option {
+	return optionFunc(func(opts *Config) {
+		opts.ExpirationPolicy = policy
+	})
+}
+
+// recovers panics of user callbacks (UpdateRaw, enumeration, policies) and returns them as ErrCallbackPanic,
+// without it the panic is re-raised after the store released its locks
+func WithPanicRecovery() Option {
+	return optionFunc(func(opts *Config) {
+		opts.RecoverPanics = true
+	})
+}
+
+// mutating operations return ErrFrozen while the store is frozen instead of blocking until Unfreeze
+func WithFailWhenFrozen() Option {
+	return optionFunc(func(opts *Config) {
+		opts.FailWhenFrozen = true
+	})
+}
+
+// raw operations return ErrKeyTooLong for keys longer than the limit in bytes
+func WithMaxKeyLength(value int) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MaxKeyLength = value
+	})
+}
+
+// selects how MaxEntries chooses victims, TTLOnly by default, only active when WithMaxEntries is set
+func WithEvictionPolicy(value EvictionPolicy) Option {
+	return optionFunc(func(opts *Config) {
+		opts.EvictionPolicy = value
+	})
+}
+
+// GetRaw returns ErrExpired instead of os.ErrNotExist for the required key reaped by expiration within the grace period,
+// keys are detected once the sweep reaps them, so pair it with a short cleanup interval
+func WithExpiredTombstones(grace time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.ExpiredGrace = grace
+	})
+}
+
+// MergeRaw consults the resolver on version conflict instead of returning ErrVersionConflict
+func WithConflictResolver(resolver func(key, current, incoming []byte) []byte) Option {
+	return optionFunc(func(opts *Config) {
+		opts.ConflictResolver = resolver
+	})
+}
+
+// SetRawStream fails with ErrValueTooLarge once the reader exceeds the limit
+func WithMaxStreamSize(value int64) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MaxStreamSize = value
+	})
+}
+
+// counts GetRaw hits per entry, the counter starts over when the key is set again
+func WithAccessTracking() Option {
+	return optionFunc(func(opts *Config) {
+		opts.AccessTracking = true
+	})
+}
+
+// fills ValueBytesTotal, ValueCount and MaxValueSize of Stats
+func WithValueSizeStats() Option {
+	return optionFunc(func(opts *Config) {
+		opts.ValueSizeStats = true
+	})
+}
+
+// rounds every computed expiration time up to the next multiple of value, so nodes writing the same key
+// at about the same time get identical expirations, the ttl is never shorter than requested but may exceed MaxTTL by less than value
+func WithTTLRounding(value time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.TTLRounding = value
+	})
+}
+
+// keeps keys in a heap by expiration time so SweepExpired touches only due entries instead of scanning the whole cache,
+// costs a heap push per write, entries put into the cache bypassing the store are swept by the go-cache janitor only
+func WithExpirationIndex() Option {
+	return optionFunc(func(opts *Config) {
+		opts.ExpirationIndex = true
+	})
+}
+
+// sets the codec of SetObject and GetObject
+func WithCodec(value Codec) Option {
+	return optionFunc(func(opts *Config) {
+		opts.Codec = value
+	})
+}
+
+// SetObject and GetObject use encoding/json
+func WithJSONCodec() Option {
+	return WithCodec(jsonCodec{})
+}
+
+// by default enumeration surfaces values that are not []byte, put into the cache directly via FromCache,
+// encoded by the codec or with nil value, strict mode skips them silently
+func WithStrictByteValues(value bool) Option {
+	return optionFunc(func(opts *Config) {
+		opts.StrictByteValues = value
+	})
+}
+
+// scopes the store to its own keys in the cache shared via FromCache, every key gets the prefix in the cache,
+// enumeration, drops and counters see only the keys of the store, for example WithNamespace(name + ":"),
+// evictions go to the store with the longest namespace matching the key
+func WithNamespace(prefix string) Option {
+	return optionFunc(func(opts *Config) {
+		opts.Namespace = prefix
+	})
+}
+
+// keeps the last size get, set, update, cas, touch and remove operations in a ring buffer for RecentOps,
+// a debugging aid costing one allocation per logged operation
+func WithOperationLog(size int) Option {
+	return optionFunc(func(opts *Config) {
+		opts.OperationLog = size
+	})
+}
+
+// RemoveRaw, RemoveRawExisted and PopRaw leave a deletion marker for the grace period, GetRaw misses the key right away
+// and EnumerateSince reports the removal as the entry with nil value, markers are purged by the sweep
+func WithSoftDelete(grace time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.SoftDeleteGrace = grace
+	})
+}
+
+// the validator runs before the value is written by SetRaw, UpdateRaw and the other writes of the store,
+// its error aborts the write and is returned to the caller unchanged, the validator must not write to the store
+func WithValueValidator(validator func(key, value []byte) error) Option {
+	return optionFunc(func(opts *Config) {
+		opts.ValueValidator = validator
+	})
+}
+
+// runs the compaction in the background every interval until Destroy or Close, the cycle sweeps expired entries
+// once their share in the cache reaches discardRatio, a slow cycle makes the next ones skip instead of overlapping
+func WithAutoCompact(interval time.Duration, discardRatio float64) Option {
+	return optionFunc(func(opts *Config) {
+		opts.AutoCompactInterval = interval
+		opts.AutoCompactRatio = discardRatio
+	})
+}
+
+// tracks the first prefixLen bytes of every key in a counting bloom filter of the given number of counters,
+// EnumerateRaw with a prefix at least that long returns right away when no key under it can exist.
+// Costs a lookup and four atomic adds per new key, keys put into the cache bypassing the store are not tracked
+func WithPrefixBloom(prefixLen int, counters int) Option {
+	return optionFunc(func(opts *Config) {
+		opts.PrefixBloomLen = prefixLen
+		opts.PrefixBloomCounters = counters
+	})
+}
 
+// registers the store in the registry under its name, DefaultRegistry is the usual choice
+func WithRegistry(r *Registry) Option {
+	return optionFunc(func(opts *Config) {
+		opts.Registry = r
+	})
+}
+
+// evicts entries neither read nor written within idle even if their ttl has not elapsed, enables access tracking,
+// the background sweep runs every half of idle until Destroy or Close
+func WithIdleEviction(idle time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.IdleTimeout = idle
+	})
+}
+
+// collapses SetRaw calls of the same key within the window into one write of the last value at the end of the window,
+// GetRaw serves the pending value, other operations on the key write it first, Close and Destroy flush all pending writes
+func WithWriteCoalescing(window time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.WriteCoalescing = window
+	})
+}
+
+// evicts the oldest entries when the heap of the process grows above targetHeapMB, checked once a second
+// until Destroy or Close, evictions are counted in Stats and go to the spillover store if configured
+func WithMemoryPressureEviction(targetHeapMB uint64) Option {
+	return optionFunc(func(opts *Config) {
+		opts.TargetHeapMB = targetHeapMB
+	})
+}
+
+// raw operations with nil or empty key return ErrEmptyKey, by default the empty key is a valid key
+func WithRejectEmptyKeys() Option {
+	return optionFunc(func(opts *Config) {
+		opts.RejectEmptyKeys = true
+	})
+}
+
+// runs Verify every interval until Destroy or Close and logs the mismatch found, each run freezes the store
+// for the walk over all entries, so it is meant for debug and test deployments
+func WithPeriodicVerify(interval time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.VerifyInterval = interval
+	})
+}
+
+// caps the wall-clock duration of every enumeration including the time spent in callbacks, the enumeration
+// running over it stops before the next callback and returns the error wrapping ErrEnumerationTimeout,
+// the entries already emitted are the partial result
+func WithMaxEnumerationDuration(max time.Duration) Option {
+	return optionFunc(func(opts *Config) {
+		opts.MaxEnumerationDuration = max
+	})
+}
+
+// picks every victim of MaxEntries eviction as the first by EvictionPolicy among k sampled entries instead of ranking
+// all entries, approximate LRU when the policy is LRU, k <= 0 keeps the exact ranking
+func WithEvictionSampleSize(k int) Option {
+	return optionFunc(func(opts *Config) {
+		opts.EvictionSampleSize = k
+	})
+}
+
+// reports contentType for every entry written without an explicit one on typed reads and enumerations,
+// SetRawTyped overrides it per entry, entries do not store the default, so changing it applies to existing ones
+func WithDefaultContentType(contentType string) Option {
+	return optionFunc(func(opts *Config) {
+		opts.DefaultContentType = contentType
+	})
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/keyvalstore/store"
+	"os"
+	"time"
+)
+
+var _ store.ManagedDataStore = (*cacheStore)(nil)
+
+// keys used by Conformance, removed when it returns
+var conformancePrefix = []byte("conformance:")
+
+// Conformance exercises the store.DataStore contract against any implementation and returns the first violation,
+// it writes only keys under the "conformance:" prefix and takes over a second because of the ttl check
+func Conformance(ds store.DataStore) error {
+
+	ctx := context.Background()
+	key := func(name string) []byte {
+		return append(append([]byte{}, conformancePrefix...), name...)
+	}
+	defer func() {
+		for _, name := range []string{"value", "ttl", "cas", "counter", "enum/1", "enum/2", "enum/3"} {
+			ds.RemoveRaw(ctx, key(name))
+		}
+	}()
+
+	// set and get
+	if err := ds.SetRaw(ctx, key("value"), []byte("v1"), store.NoTTL); err != nil {
+		return fmt.Errorf("conformance: set, %v", err)
+	}
+	value, err := ds.GetRaw(ctx, key("value"), nil, nil, true)
+	if err != nil {
+		return fmt.Errorf("conformance: get, %v", err)
+	}
+	if !bytes.Equal(value, []byte("v1")) {
+		return fmt.Errorf("conformance: get returned '%s' instead of 'v1'", value)
+	}
+	if value, err := ds.GetRaw(ctx, key("missing"), nil, nil, false); err != nil || value != nil {
+		return fmt.Errorf("conformance: get of missing key not required returned '%s', %v", value, err)
+	}
+	if _, err := ds.GetRaw(ctx, key("missing"), nil, nil, true); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("conformance: get of missing key required must fail with os.ErrNotExist, got %v", err)
+	}
+
+	// ttl
+	if err := ds.SetRaw(ctx, key("ttl"), []byte("v"), 1); err != nil {
+		return fmt.Errorf("conformance: set with ttl, %v", err)
+	}
+	if value, _ := ds.GetRaw(ctx, key("ttl"), nil, nil, false); value == nil {
+		return errors.New("conformance: entry with ttl is missing before expiration")
+	}
+	if err := ds.TouchRaw(ctx, key("ttl"), 1); err != nil {
+		return fmt.Errorf("conformance: touch, %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if value, _ := ds.GetRaw(ctx, key("ttl"), nil, nil, false); value != nil {
+		return errors.New("conformance: entry is still visible after ttl")
+	}
+
+	// compare and set
+	if err := ds.SetRaw(ctx, key("cas"), []byte("v1"), store.NoTTL); err != nil {
+		return fmt.Errorf("conformance: set, %v", err)
+	}
+	var version int64
+	if _, err := ds.GetRaw(ctx, key("cas"), nil, &version, true); err != nil {
+		return fmt.Errorf("conformance: get with version, %v", err)
+	}
+	if ok, err := ds.CompareAndSetRaw(ctx, key("cas"), []byte("v2"), store.NoTTL, version); err != nil || !ok {
+		return fmt.Errorf("conformance: compare and set with the current version failed, %v", err)
+	}
+	if ok, err := ds.CompareAndSetRaw(ctx, key("cas"), []byte("v3"), store.NoTTL, version); err != nil || ok {
+		return fmt.Errorf("conformance: compare and set with the stale version succeeded, %v", err)
+	}
+	if value, _ := ds.GetRaw(ctx, key("cas"), nil, nil, true); !bytes.Equal(value, []byte("v2")) {
+		return fmt.Errorf("conformance: compare and set stored '%s' instead of 'v2'", value)
+	}
+
+	// increment
+	if prev, err := ds.IncrementRaw(ctx, key("counter"), 10, 5, store.NoTTL); err != nil || prev != 10 {
+		return fmt.Errorf("conformance: first increment returned %d instead of initial 10, %v", prev, err)
+	}
+	if prev, err := ds.IncrementRaw(ctx, key("counter"), 10, 5, store.NoTTL); err != nil || prev != 15 {
+		return fmt.Errorf("conformance: second increment returned %d instead of 15, %v", prev, err)
+	}
+
+	// enumerate
+	expected := map[string]string{"enum/1": "a", "enum/2": "b", "enum/3": "c"}
+	for name, v := range expected {
+		if err := ds.SetRaw(ctx, key(name), []byte(v), store.NoTTL); err != nil {
+			return fmt.Errorf("conformance: set, %v", err)
+		}
+	}
+	found := make(map[string]string)
+	err = ds.EnumerateRaw(ctx, key("enum/"), key("enum/"), 2, false, false, func(entry *store.RawEntry) bool {
+		found[string(entry.Key[len(conformancePrefix):])] = string(entry.Value)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("conformance: enumerate, %v", err)
+	}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		return fmt.Errorf("conformance: enumerate returned %v instead of %v", found, expected)
+	}
+
+	// remove
+	if err := ds.RemoveRaw(ctx, key("value")); err != nil {
+		return fmt.Errorf("conformance: remove, %v", err)
+	}
+	if _, err := ds.GetRaw(ctx, key("value"), nil, nil, true); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("conformance: removed key is still visible, %v", err)
+	}
+
+	return nil
+}
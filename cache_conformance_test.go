@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+
+	cases := map[string][]Option{
+		"default":   nil,
+		"namespace": {WithNamespace("ns:")},
+	}
+
+	for name, options := range cases {
+		options := options
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := New("test", options...)
+			defer s.Destroy()
+			ctx := context.Background()
+			s.SetRaw(ctx, []byte("other"), []byte("v"), NeverExpire)
+
+			if err := Conformance(s); err != nil {
+				t.Fatal(err)
+			}
+			if value, _ := s.GetRaw(ctx, []byte("other"), nil, nil, true); string(value) != "v" {
+				t.Errorf("Conformance changed a key outside its prefix, got %q", value)
+			}
+			if err := s.EnumerateRaw(ctx, conformancePrefix, nil, 0, true, false, func(entry *store.RawEntry) bool {
+				t.Errorf("Conformance left key %q", entry.Key)
+				return true
+			}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
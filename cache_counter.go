@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/keyvalstore/store"
+	"strconv"
+	"time"
+)
+
+// number of windows a bucket outlives its own window
+const bucketRetention = 4
+
+// IncrementBucketRaw increments the counter of the current time window derived from keyPrefix and returns the window total,
+// buckets are kept for a few windows so SumBucketsRaw can look back up to that many windows
+func (t *cacheStore) IncrementBucketRaw(ctx context.Context, keyPrefix []byte, window time.Duration, delta int64) (windowTotal int64, err error) {
+
+	if window <= 0 {
+		return 0, ErrInvalidWindow
+	}
+
+	ttl := window * bucketRetention
+	ttlSeconds := int((ttl + time.Second - 1) / time.Second)
+
+	prev, err := t.IncrementRaw(ctx, bucketKey(keyPrefix, window, time.Now()), 0, delta, ttlSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return prev + delta, nil
+}
+
+// SumBucketsRaw sums the counters of the current and numWindows-1 previous windows for the sliding estimate
+func (t *cacheStore) SumBucketsRaw(ctx context.Context, keyPrefix []byte, window time.Duration, numWindows int) (int64, error) {
+
+	if window <= 0 {
+		return 0, ErrInvalidWindow
+	}
+
+	var sum int64
+	now := time.Now()
+
+	for i := 0; i < numWindows; i++ {
+		value, err := t.GetRaw(ctx, bucketKey(keyPrefix, window, now.Add(-window*time.Duration(i))), nil, nil, false)
+		if err != nil {
+			return 0, err
+		}
+		sum += decodeCounter(value)
+	}
+
+	return sum, nil
+}
+
+// IncrementWindowRaw adds delta to the counter of the current window and returns the counter, once the window elapses
+// the counter starts over from delta and the window advances by whole windows, the value is stored as
+// 8 bytes big endian window start in unix nanoseconds followed by 8 bytes big endian counter
+func (t *cacheStore) IncrementWindowRaw(ctx context.Context, key []byte, delta int64, window time.Duration) (current int64, err error) {
+
+	if window <= 0 {
+		return 0, ErrInvalidWindow
+	}
+
+	ttlSeconds := int((window + time.Second - 1) / time.Second)
+
+	err = t.UpdateRaw(ctx, key, func(entry *store.RawEntry) bool {
+		now := time.Now().UnixNano()
+		start, counter := now, int64(0)
+		if len(entry.Value) >= 16 {
+			start = int64(binary.BigEndian.Uint64(entry.Value))
+			counter = int64(binary.BigEndian.Uint64(entry.Value[8:]))
+		}
+		if elapsed := now - start; elapsed < 0 {
+			start, counter = now, 0
+		} else if elapsed >= int64(window) {
+			start, counter = now-elapsed%int64(window), 0
+		}
+		current = counter + delta
+		entry.Value = make([]byte, 16)
+		binary.BigEndian.PutUint64(entry.Value, uint64(start))
+		binary.BigEndian.PutUint64(entry.Value[8:], uint64(current))
+		entry.Ttl = ttlSeconds
+		return true
+	})
+	return
+}
+
+// RotateCounterRaw moves the counter of fromKey to toKey and resets fromKey to zero under the locks of both keys,
+// toKey gets ttlSeconds, fromKey keeps its expiration, an absent fromKey moves zero and stays absent
+func (t *cacheStore) RotateCounterRaw(ctx context.Context, fromKey, toKey []byte, ttlSeconds int) (moved int64, err error) {
+
+	if err := t.checkKey(fromKey); err != nil {
+		return 0, err
+	}
+	if err := t.checkKey(toKey); err != nil {
+		return 0, err
+	}
+	fromKey, toKey = t.nsKey(fromKey), t.nsKey(toKey)
+
+	if err := t.enterWrite(); err != nil {
+		return 0, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(toKey)
+
+	unlock, err := t.lockKeysContext(ctx, [][]byte{fromKey, toKey})
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	e, expiration, ok := t.getEntryWithExpiration(string(fromKey))
+	if ok {
+		moved = decodeCounter(e.Value)
+	}
+
+	// validate both values before writing so a rejected one leaves both keys untouched
+	resetFrom := ok && string(fromKey) != string(toKey)
+	if err := t.validate(toKey, encodeCounter(moved)); err != nil {
+		return 0, err
+	}
+	if resetFrom {
+		if err := t.validate(fromKey, encodeCounter(0)); err != nil {
+			return 0, err
+		}
+	}
+
+	t.setLocked(toKey, encodeCounter(moved), ttlSeconds)
+	if resetFrom {
+		t.keepExpiration(fromKey, encodeCounter(0), expiration)
+	}
+	return moved, nil
+}
+
+func bucketKey(keyPrefix []byte, window time.Duration, at time.Time) []byte {
+	bucket := at.UnixNano() / int64(window)
+	key := make([]byte, len(keyPrefix), len(keyPrefix)+20)
+	copy(key, keyPrefix)
+	return strconv.AppendInt(key, bucket, 10)
+}
+
+// counters are stored as 8 bytes big endian, anything shorter counts as zero
+func decodeCounter(value []byte) int64 {
+	if len(value) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+func encodeCounter(counter int64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(counter))
+	return value
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRotateCounterRawValidates(t *testing.T) {
+
+	errRejected := errors.New("rejected")
+	for _, reject := range []string{"from", "to"} {
+		reject := reject
+		t.Run(reject, func(t *testing.T) {
+			armed := false
+			s := New("test", WithValueValidator(func(key, value []byte) error {
+				if armed && string(key) == reject {
+					return errRejected
+				}
+				return nil
+			}))
+			defer s.Destroy()
+			ctx := context.Background()
+			s.SetRaw(ctx, []byte("from"), encodeCounter(5), NeverExpire)
+			s.SetRaw(ctx, []byte("to"), encodeCounter(7), NeverExpire)
+			armed = true
+
+			if _, err := s.RotateCounterRaw(ctx, []byte("from"), []byte("to"), NeverExpire); err != errRejected {
+				t.Fatalf("RotateCounterRaw err = %v, want %v", err, errRejected)
+			}
+			for key, want := range map[string]int64{"from": 5, "to": 7} {
+				value, _ := s.GetRaw(ctx, []byte(key), nil, nil, true)
+				if got := decodeCounter(value); got != want {
+					t.Errorf("%s = %d, want %d", key, got, want)
+				}
+			}
+		})
+	}
+}
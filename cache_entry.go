@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"encoding/gob"
+	"sync/atomic"
+	"time"
+)
+
+// entry is the object stored in go-cache, it is never modified after the store except the access counters,
+// every write puts the new entry with the next version of the store
+type entry struct {
+	// access counters are runtime only and updated atomically, keep them first for alignment
+	lastAccess int64
+	hits       int64
+	Value      []byte
+	Version    int64
+	// unix nanoseconds when the value was written, zero for values put into the cache directly
+	Created int64
+	// set by SetRawTyped, any other write of the key clears it
+	ContentType string
+	// end of the fresh period in unix nanoseconds set by SetRawTiered, zero for entries without tiers
+	FreshUntil int64
+}
+
+func init() {
+	gob.Register(&entry{})
+}
+
+// ValueOf returns the value of the object read from the cache returned by Instance, both the entries written by the store
+// and plain []byte values put into the cache directly are accepted
+func ValueOf(obj interface{}) ([]byte, bool) {
+	if e, ok := toEntry(obj); ok {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// toEntry accepts entries written by the store and plain []byte values put into the cache directly, the latter have version 0
+func toEntry(obj interface{}) (*entry, bool) {
+	switch v := obj.(type) {
+	case *entry:
+		return v, true
+	case []byte:
+		return &entry{Value: v}, true
+	}
+	return nil, false
+}
+
+// versions are store-wide and strictly increasing, so they also order writes across keys
+func (t *cacheStore) nextVersion() int64 {
+	return atomic.AddInt64(&t.version, 1)
+}
+
+func (t *cacheStore) newEntry(value []byte) *entry {
+	now := time.Now().UnixNano()
+	e := &entry{Value: value, Version: t.nextVersion(), Created: now}
+	if t.trackAccess() {
+		e.lastAccess = now
+	}
+	return e
+}
+
+// access tracking is needed by the eviction policies that look at reads and by the hot keys report
+func (t *cacheStore) trackAccess() bool {
+	return t.conf.AccessTracking || t.conf.IdleTimeout > 0 || t.conf.MaxEntries > 0 && t.conf.EvictionPolicy != TTLOnly
+}
+
+func (e *entry) accessed() {
+	atomic.StoreInt64(&e.lastAccess, time.Now().UnixNano())
+	atomic.AddInt64(&e.hits, 1)
+}
+
+// advanceVersion makes sure the next version is above the restored one
+func (t *cacheStore) advanceVersion(version int64) {
+	for {
+		current := atomic.LoadInt64(&t.version)
+		if version <= current || atomic.CompareAndSwapInt64(&t.version, current, version) {
+			return
+		}
+	}
+}
+
+// enumEntry converts the cached object for enumeration, values put into the cache directly that are not []byte
+// surface encoded by the codec or with nil value unless StrictByteValues is set
+func (t *cacheStore) enumEntry(obj interface{}) (*entry, bool) {
+	if e, ok := toEntry(obj); ok {
+		return e, true
+	}
+	if obj == nil || t.conf.StrictByteValues {
+		return nil, false
+	}
+	e := &entry{}
+	if t.conf.Codec != nil {
+		if value, err := t.conf.Codec.Marshal(obj); err == nil {
+			e.Value = value
+		}
+	}
+	return e, true
+}
+
+func (t *cacheStore) getEnumEntry(key string) (*entry, int64, bool) {
+	obj, exp, ok := t.cache.GetWithExpiration(key)
+	if !ok {
+		return nil, 0, false
+	}
+	e, ok := t.enumEntry(obj)
+	if !ok {
+		return nil, 0, false
+	}
+	var expiration int64
+	if !exp.IsZero() {
+		expiration = exp.UnixNano()
+	}
+	return e, expiration, true
+}
+
+// setItem is used for every write that puts the new entry into the cache, so the value size accounting stays in sync
+func (t *cacheStore) setItem(key string, e *entry, ttl time.Duration) {
+	t.bloomAdded(key)
+	t.cache.Set(key, e, ttl)
+	t.expires(key, ttl)
+	if t.conf.ValueSizeStats {
+		t.sizes.put(key, len(e.Value))
+	}
+}
+
+func (t *cacheStore) getEntry(key string) (*entry, bool) {
+	obj, ok := t.cache.Get(key)
+	if !ok || obj == nil {
+		return nil, false
+	}
+	return toEntry(obj)
+}
+
+// getEntryWithExpiration returns expiration in unix nanoseconds, zero for no expiration
+func (t *cacheStore) getEntryWithExpiration(key string) (*entry, int64, bool) {
+	obj, exp, ok := t.cache.GetWithExpiration(key)
+	if !ok || obj == nil {
+		return nil, 0, false
+	}
+	e, ok := toEntry(obj)
+	if !ok {
+		return nil, 0, false
+	}
+	var expiration int64
+	if !exp.IsZero() {
+		expiration = exp.UnixNano()
+	}
+	return e, expiration, true
+}
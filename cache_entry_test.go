@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestValueOfInstanceObjects(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	c := s.Instance().(*cache.Cache)
+
+	s.SetRaw(context.Background(), []byte("store"), []byte("1"), NeverExpire)
+	c.Set("direct", []byte("2"), cache.NoExpiration)
+	c.Set("other", 3, cache.NoExpiration)
+
+	for key, want := range map[string]string{"store": "1", "direct": "2"} {
+		obj, _ := c.Get(key)
+		if value, ok := ValueOf(obj); !ok || string(value) != want {
+			t.Errorf("ValueOf(%s) = %q, %v, want %q", key, value, ok, want)
+		}
+	}
+	if obj, _ := c.Get("other"); obj != nil {
+		if _, ok := ValueOf(obj); ok {
+			t.Error("ValueOf accepted a non-byte object")
+		}
+	}
+	if value, _ := s.GetRaw(context.Background(), []byte("direct"), nil, nil, true); string(value) != "2" {
+		t.Errorf("GetRaw of the direct value = %q", value)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ETag returns the strong entity tag of the value, the quoted hex of the first 16 bytes of its sha256
+func ETag(value []byte) string {
+	sum := sha256.Sum256(value)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// GetRawIfNoneMatch is the conditional GET, etag is the If-None-Match value of the caller, a list of tags or "*".
+// Returns notModified with nil value when the tag of the stored value matches, the value otherwise,
+// etagOut is the tag of the stored value in both cases. The tag is derived from the content, so it is
+// the same for equal values and survives backup and restore. Absent key returns nil value, empty etagOut and no error
+func (t *cacheStore) GetRawIfNoneMatch(ctx context.Context, key []byte, etag string) (value []byte, etagOut string, notModified bool, err error) {
+
+	e, err := t.getRawEntry(ctx, key, false)
+	if e == nil {
+		return nil, "", false, err
+	}
+
+	etagOut = ETag(e.Value)
+	if etagMatch(etag, etagOut) {
+		return nil, etagOut, true, nil
+	}
+	return e.Value, etagOut, false, nil
+}
+
+// etagMatch is the weak comparison of If-None-Match
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
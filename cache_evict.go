@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+	"github.com/patrickmn/go-cache"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// EvictionPolicy selects victims when the store is over MaxEntries
+type EvictionPolicy int
+
+const (
+	// evicts entries soonest to expire, entries without expiration go last
+	TTLOnly EvictionPolicy = iota
+	// evicts least recently accessed entries
+	LRU
+	// evicts least frequently accessed entries, the least recently accessed among equals
+	LFU
+)
+
+type evictionCandidate struct {
+	key        string
+	expiration int64
+	lastAccess int64
+	hits       int64
+}
+
+// evictOverflow brings the number of entries back to MaxEntries, expired entries go first
+// and then live entries selected by the eviction policy, pinned keys and the just written key are never evicted.
+// Keys locked meanwhile are passed over for the next candidates, the caller may be nested in the callback
+// of a write holding such a lock. Must be called without holding the lock of the written key.
+func (t *cacheStore) evictOverflow(written []byte) {
+
+	max := t.conf.MaxEntries
+	if max <= 0 || t.itemCount() <= max {
+		return
+	}
+
+	t.evictMu.Lock()
+	defer t.evictMu.Unlock()
+
+	if t.itemCount() <= max {
+		return
+	}
+
+	t.SweepExpired()
+
+	exclude := map[string]bool{string(written): true}
+	for {
+		over := t.itemCount() - max
+		if over <= 0 {
+			return
+		}
+		victims := t.evictionVictims(over, exclude)
+		if len(victims) == 0 {
+			return
+		}
+		for _, key := range victims {
+			if !t.evict(key) {
+				exclude[key] = true
+			}
+		}
+	}
+}
+
+func (t *cacheStore) evictionVictims(n int, exclude map[string]bool) []string {
+
+	if t.conf.EvictionSampleSize > 0 {
+		return t.sampledVictims(n, exclude, t.conf.EvictionSampleSize)
+	}
+
+	var list []evictionCandidate
+	for key, item := range t.items() {
+		if c, ok := t.evictionCandidate(key, item, exclude); ok {
+			list = append(list, c)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].before(&list[j], t.conf.EvictionPolicy)
+	})
+
+	if n > len(list) {
+		n = len(list)
+	}
+
+	victims := make([]string, n)
+	for i := 0; i < n; i++ {
+		victims[i] = list[i].key
+	}
+	return victims
+}
+
+// sampledVictims picks every victim as the first by the eviction policy among k candidates sampled from the snapshot,
+// the random start of the map iteration makes the sample, so no sort over all entries is needed
+func (t *cacheStore) sampledVictims(n int, exclude map[string]bool, k int) []string {
+
+	items := t.items()
+	var victims []string
+
+	for len(victims) < n && len(items) > 0 {
+		var best evictionCandidate
+		found, sampled := false, 0
+		for key, item := range items {
+			c, ok := t.evictionCandidate(key, item, exclude)
+			if !ok {
+				delete(items, key)
+				continue
+			}
+			if !found || c.before(&best, t.conf.EvictionPolicy) {
+				best, found = c, true
+			}
+			if sampled++; sampled == k {
+				break
+			}
+		}
+		if !found {
+			break
+		}
+		delete(items, best.key)
+		victims = append(victims, best.key)
+	}
+
+	return victims
+}
+
+func (t *cacheStore) evictionCandidate(key string, item cache.Item, exclude map[string]bool) (evictionCandidate, bool) {
+	if exclude[key] || t.isPinned(key) {
+		return evictionCandidate{}, false
+	}
+	c := evictionCandidate{key: key, expiration: item.Expiration}
+	if e, ok := item.Object.(*entry); ok {
+		c.lastAccess = atomic.LoadInt64(&e.lastAccess)
+		c.hits = atomic.LoadInt64(&e.hits)
+	}
+	return c, true
+}
+
+// before reports whether c should be evicted before o
+func (c *evictionCandidate) before(o *evictionCandidate, policy EvictionPolicy) bool {
+	switch policy {
+	case LRU:
+		return c.lastAccess < o.lastAccess
+	case LFU:
+		if c.hits != o.hits {
+			return c.hits < o.hits
+		}
+		return c.lastAccess < o.lastAccess
+	default:
+		if c.expiration == 0 {
+			return false
+		}
+		return o.expiration == 0 || c.expiration < o.expiration
+	}
+}
+
+// evict removes the key unless its lock is held, a held lock is reported by false
+func (t *cacheStore) evict(key string) bool {
+
+	unlock, ok := t.locks.tryLockKey([]byte(key))
+	if !ok {
+		return false
+	}
+	defer unlock()
+
+	if e, expiration, ok := t.getEntryWithExpiration(key); ok {
+		t.evictLocked(key, e, expiration)
+	}
+	return true
+}
+
+// evictLocked is called under the key lock
+func (t *cacheStore) evictLocked(key string, e *entry, expiration int64) {
+	t.deleteKey(key)
+	t.stats.evict()
+
+	if t.conf.Spillover != nil {
+		t.spill(key, e.Value, expiration)
+	}
+}
+
+// spillover failures are not fatal, the entry is lost as with plain eviction
+func (t *cacheStore) spill(key string, value []byte, expiration int64) {
+	err := t.conf.Spillover.SetRaw(context.Background(), []byte(t.userKey(key)), value, remainingSeconds(expiration, time.Now().UnixNano()))
+	if err != nil {
+		t.stats.spillError()
+		log.Printf("cachestore '%s': spillover of key '%s' failed, %v", t.name, key, err)
+	}
+}
+
+// unspill removes the key from the spillover store on removal, an older spilled copy would be promoted
+// back by the next read otherwise
+func (t *cacheStore) unspill(ctx context.Context, key []byte) {
+	if t.conf.Spillover == nil {
+		return
+	}
+	if err := t.conf.Spillover.RemoveRaw(ctx, t.userRawKey(key)); err != nil {
+		t.stats.spillError()
+		log.Printf("cachestore '%s': remove of key '%s' from spillover failed, %v", t.name, string(key), err)
+	}
+}
+
+// promote moves the entry from the spillover store back into the cache on miss
+func (t *cacheStore) promote(ctx context.Context, key []byte) (*entry, error) {
+
+	defer t.evictOverflow(key)
+
+	unlock := t.locks.lockKey(key)
+	defer unlock()
+
+	if e, ok := t.getEntry(string(key)); ok {
+		return e, nil
+	}
+
+	var ttlSeconds int
+	value, err := t.conf.Spillover.GetRaw(ctx, t.userRawKey(key), &ttlSeconds, nil, false)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	if ttlSeconds == store.NoTTL {
+		ttlSeconds = NeverExpire
+	}
+	e := t.newEntry(value)
+	t.setItem(string(key), e, t.expiration(ttlSeconds))
+	if err := t.conf.Spillover.RemoveRaw(ctx, t.userRawKey(key)); err != nil {
+		t.stats.spillError()
+		log.Printf("cachestore '%s': remove of promoted key '%s' from spillover failed, %v", t.name, string(key), err)
+	}
+
+	return e, nil
+}
+
+// SetRawNoEvict stores the value only if it fits into MaxEntries, returns ErrCacheFull instead of evicting
+func (t *cacheStore) SetRawNoEvict(ctx context.Context, key, value []byte, ttlSeconds int) error {
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.cache.Get(string(key)); !ok && t.isFull() {
+		return ErrCacheFull
+	}
+
+	t.setLocked(key, value, ttlSeconds)
+	return nil
+}
+
+// expired entries waiting for the sweep do not count
+func (t *cacheStore) isFull() bool {
+	max := t.conf.MaxEntries
+	if max <= 0 || t.itemCount() < max {
+		return false
+	}
+	return len(t.items()) >= max
+}
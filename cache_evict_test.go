@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"fmt"
+	"github.com/keyvalstore/store"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvictionNestedInCallback(t *testing.T) {
+
+	keep := func(key, value []byte) (bool, time.Duration) {
+		return true, time.Minute
+	}
+	cases := map[string]struct {
+		options []Option
+		ttl     int
+	}{
+		"lru":     {[]Option{WithEvictionPolicy(LRU)}, NeverExpire},
+		"sampled": {[]Option{WithEvictionSampleSize(4)}, NeverExpire},
+		// the key of the outer write is expired, so the nested sweep meets it
+		"expiration policy": {[]Option{WithExpirationPolicy(keep)}, 1},
+		"expiration index":  {[]Option{WithExpirationIndex()}, 1},
+	}
+
+	ctx := context.Background()
+	stores := make(map[string]*cacheStore)
+	for name, c := range cases {
+		s := New("test", append(c.options, WithMaxEntries(1))...)
+		defer s.Destroy()
+		s.SetRaw(ctx, []byte("a"), []byte("1"), c.ttl)
+		stores[name] = s
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	for name, s := range stores {
+		s := s
+		t.Run(name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				done <- s.UpdateRaw(ctx, []byte("a"), func(entry *store.RawEntry) bool {
+					if err := s.SetRaw(ctx, []byte("b"), []byte("2"), NeverExpire); err != nil {
+						t.Errorf("nested SetRaw: %v", err)
+					}
+					entry.Value = []byte("3")
+					return true
+				})
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("UpdateRaw: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("eviction nested in the callback deadlocked")
+			}
+			if value, _ := s.GetRaw(ctx, []byte("a"), nil, nil, true); string(value) != "3" {
+				t.Errorf("outer key = %q, want 3", value)
+			}
+		})
+	}
+}
+
+// exact LRU against sampled eviction on a zipfian read-through workload over 10000 keys with room for 500,
+// reports the hit rate next to the throughput
+func BenchmarkEvictionZipf(b *testing.B) {
+
+	for _, k := range []int{0, 5, 16} {
+		name := "exact"
+		if k > 0 {
+			name = fmt.Sprintf("sample/%d", k)
+		}
+		b.Run(name, func(b *testing.B) {
+			s := New("bench", WithMaxEntries(500), WithEvictionPolicy(LRU), WithEvictionSampleSize(k))
+			defer s.Destroy()
+			ctx := context.Background()
+			zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, 9999)
+
+			keys := make([][]byte, 10000)
+			for i := range keys {
+				keys[i] = []byte(strconv.Itoa(i))
+			}
+
+			hits := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[zipf.Uint64()]
+				if value, _ := s.GetRaw(ctx, key, nil, nil, false); value != nil {
+					hits++
+				} else {
+					s.SetRaw(ctx, key, []byte("v"), NeverExpire)
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N), "hitrate")
+		})
+	}
+}
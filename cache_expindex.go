@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"container/heap"
+	"github.com/patrickmn/go-cache"
+	"sync"
+	"time"
+)
+
+// expirationIndex is the min-heap of keys by expiration time, every expiration change pushes a new item
+// and outdated items are dropped lazily when they come to the top, so a sweep touches only due keys
+type expirationIndex struct {
+	mu    sync.Mutex
+	items expirationHeap
+}
+
+type expirationItem struct {
+	at  int64
+	key string
+}
+
+type expirationHeap []expirationItem
+
+func (h expirationHeap) Len() int            { return len(h) }
+func (h expirationHeap) Less(i, j int) bool  { return h[i].at < h[j].at }
+func (h expirationHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expirationHeap) Push(x interface{}) { *h = append(*h, x.(expirationItem)) }
+func (h *expirationHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func (t *expirationIndex) push(key string, at int64) {
+	t.mu.Lock()
+	heap.Push(&t.items, expirationItem{at: at, key: key})
+	t.mu.Unlock()
+}
+
+// popDue removes and returns the keys due at now, some of them may have been rewritten or removed since
+func (t *expirationIndex) popDue(now int64) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var keys []string
+	for len(t.items) > 0 && t.items[0].at <= now {
+		keys = append(keys, heap.Pop(&t.items).(expirationItem).key)
+	}
+	return keys
+}
+
+func (t *expirationIndex) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.items)
+}
+
+// rebuild indexes the live items of the cache, dropping the outdated items
+func (t *expirationIndex) rebuild(live map[string]cache.Item) {
+	var items expirationHeap
+	for key, item := range live {
+		if item.Expiration > 0 {
+			items = append(items, expirationItem{at: item.Expiration, key: key})
+		}
+	}
+	heap.Init(&items)
+	t.mu.Lock()
+	t.items = items
+	t.mu.Unlock()
+}
+
+func (t *expirationIndex) reset() {
+	t.mu.Lock()
+	t.items = nil
+	t.mu.Unlock()
+}
+
+// expires records the new expiration of the key, called after every cache.Set of the store
+func (t *cacheStore) expires(key string, ttl time.Duration) {
+	if t.conf.ExpirationIndex && ttl > 0 {
+		t.expIndex.push(key, time.Now().Add(ttl).UnixNano())
+	}
+}
+
+// sweepIndex reaps the due keys found by the index, a key that is still live was rewritten and is skipped,
+// the reap runs under the key lock and the eviction callback is told so by the reaping mark.
+// A locked key goes back to the index for the next sweep, the sweep may run nested in the callback of its writer
+func (t *cacheStore) sweepIndex() {
+
+	now := time.Now().UnixNano()
+	for _, key := range t.expIndex.popDue(now) {
+		unlock, ok := t.locks.tryLockKey([]byte(key))
+		if !ok {
+			t.expIndex.push(key, now)
+			continue
+		}
+		if _, ok := t.cache.Get(key); !ok {
+			t.reaping.mark(key)
+			t.cache.Delete(key)
+			t.reaping.unmark(key)
+		}
+		unlock()
+	}
+
+	if n := t.cache.ItemCount(); t.expIndex.len() > 2*n+1024 {
+		t.expIndex.rebuild(t.items())
+	}
+}
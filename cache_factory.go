@@ -12,6 +12,11 @@ import (
 )
 
 func OpenDatabase(options ...Option) *cache.Cache {
+	conf := newConfig(options...)
+	return cache.New(conf.DefaultExpiration, conf.CleanupInterval)
+}
+
+func newConfig(options ...Option) *Config {
 
 	conf := &Config{
 		DefaultExpiration: cache.NoExpiration,
@@ -22,7 +27,12 @@ func OpenDatabase(options ...Option) *cache.Cache {
 		opt.apply(conf)
 	}
 
-	return cache.New(conf.DefaultExpiration, conf.CleanupInterval)
+	return conf
+}
+
+// the store runs its own sweeper, so the go-cache janitor is disabled
+func openDatabase(conf *Config) *cache.Cache {
+	return cache.New(conf.DefaultExpiration, 0)
 }
 
 func ObjectType() reflect.Type {
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// freezeGate counts the mutating operations in flight, Freeze and the drop operations hold it exclusively.
+// A held or requested gate keeps new writes waiting, except the writes nested in the user callback of a write:
+// the callback may write other keys and those writes must get through, or the holder would wait forever
+// for the outer write. Nested writes are told by the goroutine running the callback, writes the callback
+// leaves to other goroutines wait like any other
+type freezeGate struct {
+	frozen int32
+	mu     sync.Mutex
+	cond   *sync.Cond
+	count  int
+	held   bool
+	// the first Freeze has drained the writes in flight
+	ready  bool
+	active int
+	// goroutines running a user callback of a write, by goroutine id
+	callbacks map[uint64]int
+}
+
+func (t *freezeGate) init() {
+	if t.cond == nil {
+		t.cond = sync.NewCond(&t.mu)
+	}
+}
+
+// hold waits for the other holder and then for the writes in flight, called with mu locked
+func (t *freezeGate) hold() {
+	t.init()
+	for t.held {
+		t.cond.Wait()
+	}
+	t.held = true
+	for t.active > 0 {
+		t.cond.Wait()
+	}
+}
+
+func (t *freezeGate) release() {
+	t.held = false
+	t.cond.Broadcast()
+}
+
+// Freeze blocks all mutating raw operations until the matching Unfreeze, reads continue.
+// It waits for in-flight writes to complete, so the store does not change until Unfreeze
+// except for the expiration of entries. Writes made by the callbacks of in-flight operations,
+// for example UpdateRaw, complete too if made on the goroutine of the callback. Calls nest, the store is unfrozen by the last Unfreeze.
+// Must not be called from the callback of a mutating operation.
+func (t *cacheStore) Freeze() {
+	t.gate.mu.Lock()
+	defer t.gate.mu.Unlock()
+	if t.gate.count++; t.gate.count == 1 {
+		atomic.StoreInt32(&t.gate.frozen, 1)
+		t.gate.hold()
+		t.gate.ready = true
+		t.gate.cond.Broadcast()
+		return
+	}
+	// nested calls return once the first one has drained the writes in flight
+	for !t.gate.ready {
+		t.gate.cond.Wait()
+	}
+}
+
+// Unfreeze releases one Freeze call
+func (t *cacheStore) Unfreeze() {
+	t.gate.mu.Lock()
+	defer t.gate.mu.Unlock()
+	if t.gate.count == 0 {
+		return
+	}
+	t.gate.count--
+	if t.gate.count == 0 {
+		atomic.StoreInt32(&t.gate.frozen, 0)
+		t.gate.ready = false
+		t.gate.release()
+	}
+}
+
+// enterWrite is called by every mutating operation before any key lock, composite operations enter only once
+func (t *cacheStore) enterWrite() error {
+	if t.conf.FailWhenFrozen && atomic.LoadInt32(&t.gate.frozen) == 1 {
+		return ErrFrozen
+	}
+	t.gate.mu.Lock()
+	t.gate.init()
+	if t.gate.held {
+		gid := goroutineID()
+		for t.gate.held && t.gate.callbacks[gid] == 0 {
+			t.gate.cond.Wait()
+		}
+	}
+	t.gate.active++
+	t.gate.mu.Unlock()
+	return nil
+}
+
+func (t *cacheStore) exitWrite() {
+	t.gate.mu.Lock()
+	if t.gate.active--; t.gate.active == 0 {
+		t.gate.cond.Broadcast()
+	}
+	t.gate.mu.Unlock()
+}
+
+// inCallback runs the user callback of a mutating operation after enterWrite,
+// writes made by the callback on its goroutine are let through the held gate
+func (t *cacheStore) inCallback(fn func()) {
+	gid := goroutineID()
+	t.gate.mu.Lock()
+	if t.gate.callbacks == nil {
+		t.gate.callbacks = make(map[uint64]int)
+	}
+	t.gate.callbacks[gid]++
+	t.gate.mu.Unlock()
+	defer func() {
+		t.gate.mu.Lock()
+		if t.gate.callbacks[gid]--; t.gate.callbacks[gid] == 0 {
+			delete(t.gate.callbacks, gid)
+		}
+		t.gate.mu.Unlock()
+	}()
+	fn()
+}
+
+// goroutineID parses the id from the header "goroutine N [" of the current stack,
+// the runtime does not expose it otherwise
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// enterExclusive is called by the drop operations instead of enterWrite, in-flight writes finish before the drop
+// and new ones wait for it, so every write lands either before or after the drop
+func (t *cacheStore) enterExclusive() error {
+	if t.conf.FailWhenFrozen && atomic.LoadInt32(&t.gate.frozen) == 1 {
+		return ErrFrozen
+	}
+	t.gate.mu.Lock()
+	t.gate.hold()
+	t.gate.mu.Unlock()
+	return nil
+}
+
+func (t *cacheStore) exitExclusive() {
+	t.gate.mu.Lock()
+	t.gate.release()
+	t.gate.mu.Unlock()
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keyvalstore/store"
+)
+
+func TestFreezeWithNestedWriteInCallback(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	ctx := context.Background()
+
+	inCallback := make(chan struct{})
+	frozen := make(chan struct{})
+	updated := make(chan error, 1)
+
+	go func() {
+		updated <- s.UpdateRaw(ctx, []byte("a"), func(entry *store.RawEntry) bool {
+			close(inCallback)
+			// let Freeze start waiting for this write
+			time.Sleep(50 * time.Millisecond)
+			if err := s.SetRaw(ctx, []byte("b"), []byte("nested"), NeverExpire); err != nil {
+				t.Errorf("nested set: %v", err)
+			}
+			entry.Value = []byte("outer")
+			return true
+		})
+	}()
+
+	<-inCallback
+	go func() {
+		s.Freeze()
+		close(frozen)
+	}()
+
+	select {
+	case err := <-updated:
+		if err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("update deadlocked with pending Freeze")
+	}
+
+	select {
+	case <-frozen:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Freeze did not complete")
+	}
+
+	written := make(chan struct{})
+	go func() {
+		s.SetRaw(ctx, []byte("c"), []byte("late"), NeverExpire)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("write went through the frozen store")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.Unfreeze()
+	<-written
+
+	for key, want := range map[string]string{"a": "outer", "b": "nested", "c": "late"} {
+		if value, _ := s.GetRaw(ctx, []byte(key), nil, nil, true); string(value) != want {
+			t.Errorf("key %s: got %q, want %q", key, value, want)
+		}
+	}
+}
+
+func TestFreezeNested(t *testing.T) {
+
+	s := New("test", WithFailWhenFrozen())
+	defer s.Destroy()
+	ctx := context.Background()
+
+	s.Freeze()
+	s.Freeze()
+	s.Unfreeze()
+	if err := s.SetRaw(ctx, []byte("a"), []byte("1"), NeverExpire); err != ErrFrozen {
+		t.Fatalf("got %v, want ErrFrozen", err)
+	}
+	s.Unfreeze()
+	if err := s.SetRaw(ctx, []byte("a"), []byte("1"), NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFreezeHoldsOtherWritersDuringCallback(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	ctx := context.Background()
+
+	inCallback := make(chan struct{})
+	updated := make(chan struct{})
+	go func() {
+		s.UpdateRaw(ctx, []byte("a"), func(entry *store.RawEntry) bool {
+			close(inCallback)
+			// Freeze and the other writer start waiting meanwhile
+			time.Sleep(100 * time.Millisecond)
+			entry.Value = []byte("outer")
+			return true
+		})
+		close(updated)
+	}()
+
+	<-inCallback
+	frozen := make(chan struct{})
+	go func() {
+		s.Freeze()
+		close(frozen)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	written := make(chan struct{})
+	go func() {
+		s.SetRaw(ctx, []byte("x"), []byte("other"), NeverExpire)
+		close(written)
+	}()
+
+	<-updated
+	<-frozen
+	select {
+	case <-written:
+		t.Fatal("write of another goroutine went through the frozen store")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if value, _ := s.GetRaw(ctx, []byte("x"), nil, nil, false); value != nil {
+		t.Fatalf("frozen store changed, got %q", value)
+	}
+
+	s.Unfreeze()
+	<-written
+}
+
+func TestFreezeValidatorKeepsGateClosed(t *testing.T) {
+
+	s := New("test", WithValueValidator(func(key, value []byte) error {
+		if string(key) == "y" {
+			// the blocked write of x must not get in while the validator runs
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}))
+	defer s.Destroy()
+	ctx := context.Background()
+
+	s.Freeze()
+	written := make(chan struct{}, 2)
+	for _, key := range []string{"x", "y"} {
+		go func(key string) {
+			s.SetRaw(ctx, []byte(key), []byte("v"), NeverExpire)
+			written <- struct{}{}
+		}(key)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case <-written:
+		t.Fatal("write went through the frozen store")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.Unfreeze()
+	<-written
+	<-written
+}
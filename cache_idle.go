@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+func (t *cacheStore) runIdleSweeper(idle time.Duration) {
+	interval := idle / 2
+	if interval <= 0 {
+		interval = idle
+	}
+	t.idler.start(interval, t.SweepIdle)
+}
+
+// SweepIdle evicts entries not read or written within IdleTimeout regardless of their ttl, pinned keys are kept,
+// evictions are counted in Stats and go to the spillover store if configured
+func (t *cacheStore) SweepIdle() {
+
+	idle := t.conf.IdleTimeout
+	if idle <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(-idle).UnixNano()
+
+	for key, item := range t.items() {
+
+		if e, ok := item.Object.(*entry); !ok || !e.idleSince(deadline) || t.isPinned(key) {
+			continue
+		}
+
+		unlock := t.locks.lockKey([]byte(key))
+		if e, expiration, ok := t.getEntryWithExpiration(key); ok && e.idleSince(deadline) {
+			t.evictLocked(key, e, expiration)
+		}
+		unlock()
+
+	}
+}
+
+// idleSince reports whether the entry was neither accessed nor written after the deadline, restored entries have only the creation time
+func (e *entry) idleSince(deadline int64) bool {
+	last := atomic.LoadInt64(&e.lastAccess)
+	if last == 0 {
+		last = e.Created
+	}
+	return last != 0 && last < deadline
+}
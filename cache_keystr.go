@@ -0,0 +1,17 @@
+//go:build !cachestore_safe
+
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import "unsafe"
+
+// lookupKey returns the string sharing the memory of key without the copy of string(key).
+// Only for lookups: the result must not be stored or outlive the call and key must not change meanwhile,
+// go-cache Get and GetWithExpiration do not retain the key. Build with the cachestore_safe tag to copy instead.
+func lookupKey(key []byte) string {
+	return *(*string)(unsafe.Pointer(&key))
+}
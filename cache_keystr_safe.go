@@ -0,0 +1,13 @@
+//go:build cachestore_safe
+
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+// lookupKey copies the key when the store is built with the cachestore_safe tag
+func lookupKey(key []byte) string {
+	return string(key)
+}
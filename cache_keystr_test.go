@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"testing"
+)
+
+// compare with go test -tags cachestore_safe to see the allocation of string(key) per lookup
+func BenchmarkGetRaw(b *testing.B) {
+
+	s := New("bench")
+	defer s.Destroy()
+	ctx := context.Background()
+	key := []byte("a key long enough to be allocated on the heap by string(key)")
+	s.SetRaw(ctx, key, []byte("value"), NeverExpire)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetRaw(ctx, key, nil, nil, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
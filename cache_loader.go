@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"sync"
+)
+
+// loadLocks are per-key locks held while the loader runs, kept apart from the write locks
+// so a slow loader blocks only the callers loading the same key
+type loadLocks struct {
+	mu   sync.Mutex
+	keys map[string]*loadLock
+}
+
+type loadLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+func (t *loadLocks) lock(ctx context.Context, key string) (func(), error) {
+
+	t.mu.Lock()
+	if t.keys == nil {
+		t.keys = make(map[string]*loadLock)
+	}
+	l, ok := t.keys[key]
+	if !ok {
+		l = &loadLock{sem: make(chan struct{}, 1)}
+		t.keys[key] = l
+	}
+	l.refs++
+	t.mu.Unlock()
+
+	release := func() {
+		t.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(t.keys, key)
+		}
+		t.mu.Unlock()
+	}
+
+	if err := acquire(ctx, l.sem); err != nil {
+		release()
+		return nil, err
+	}
+
+	return func() {
+		<-l.sem
+		release()
+	}, nil
+}
+
+// GetWithLoader returns the cached value or loads it, on a miss only one caller per key runs the loader
+// while the others wait for the load lock and reuse the stored result, loader errors are returned and not cached
+func (t *cacheStore) GetWithLoader(ctx context.Context, key []byte, ttlSeconds int, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+
+	value, err := t.GetRaw(ctx, key, nil, nil, false)
+	if err != nil || value != nil {
+		return value, err
+	}
+
+	unlock, err := t.loading.lock(ctx, string(key))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	value, err = t.GetRaw(ctx, key, nil, nil, false)
+	if err != nil || value != nil {
+		return value, err
+	}
+
+	if value, err = loader(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := t.SetRaw(ctx, key, value, ttlSeconds); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// keyLocks are per-key one-slot semaphores, so the acquisition can be bounded by the context,
+// a lock lives in the map while it is held or waited for. Distinct keys never share a lock, so the callback
+// of an operation may write other keys, writing the key of the operation itself from its callback deadlocks
+type keyLocks struct {
+	mu   sync.Mutex
+	keys map[string]*keyLock
+}
+
+type keyLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+// ref returns the lock of the key counting the caller in, unref must follow once the caller is done with it
+func (t *keyLocks) ref(key string) *keyLock {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.keys == nil {
+		t.keys = make(map[string]*keyLock)
+	}
+	l, ok := t.keys[key]
+	if !ok {
+		l = &keyLock{sem: make(chan struct{}, 1)}
+		t.keys[key] = l
+	}
+	l.refs++
+	return l
+}
+
+func (t *keyLocks) unref(key string, l *keyLock) {
+	t.mu.Lock()
+	if l.refs--; l.refs == 0 {
+		delete(t.keys, key)
+	}
+	t.mu.Unlock()
+}
+
+// lock the key and return the unlock function
+func (t *keyLocks) lockKey(key []byte) func() {
+	k := string(key)
+	l := t.ref(k)
+	l.sem <- struct{}{}
+	return func() {
+		<-l.sem
+		t.unref(k, l)
+	}
+}
+
+// tryLockKey locks the key only if it is free, used by the background reaps that may run nested
+// in the callback of a write holding the lock
+func (t *keyLocks) tryLockKey(key []byte) (func(), bool) {
+	k := string(key)
+	l := t.ref(k)
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		t.unref(k, l)
+		return nil, false
+	}
+	return func() {
+		<-l.sem
+		t.unref(k, l)
+	}, true
+}
+
+// lockKeyContext gives up with ctx.Err() when the context is done before the key lock is acquired,
+// a long running callback of another writer can not block the caller beyond its deadline
+func (t *keyLocks) lockKeyContext(ctx context.Context, key []byte) (func(), error) {
+	k := string(key)
+	l := t.ref(k)
+	if err := acquire(ctx, l.sem); err != nil {
+		t.unref(k, l)
+		return nil, err
+	}
+	return func() {
+		<-l.sem
+		t.unref(k, l)
+	}, nil
+}
+
+// lockKeysContext locks several keys at once, keys are taken in ascending order and only once each,
+// so concurrent multi-key operations can not deadlock, on failure nothing stays locked
+func (t *keyLocks) lockKeysContext(ctx context.Context, keys [][]byte) (func(), error) {
+
+	seen := make(map[string]bool, len(keys))
+	sorted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if k := string(key); !seen[k] {
+			seen[k] = true
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	unlocks := make([]func(), 0, len(sorted))
+	unlock := func() {
+		for _, fn := range unlocks {
+			fn()
+		}
+	}
+
+	for _, k := range sorted {
+		fn, err := t.lockKeyContext(ctx, []byte(k))
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		unlocks = append(unlocks, fn)
+	}
+
+	return unlock, nil
+}
+
+func acquire(ctx context.Context, sem chan struct{}) error {
+	select {
+	case sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if ctx == nil {
+		sem <- struct{}{}
+		return nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recoverCallback must be deferred directly, locks are released by their own defers in any case
+func (t *cacheStore) recoverCallback(err *error) {
+	if !t.conf.RecoverPanics {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrCallbackPanic, r)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keyvalstore/store"
+)
+
+func TestUpdateCallbackWritesOtherKeys(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// enough keys to hit any fixed number of lock stripes
+	for i := 0; i < 1024; i++ {
+		other := []byte(fmt.Sprintf("b%d", i))
+		err := s.UpdateRaw(ctx, []byte("a"), func(entry *store.RawEntry) bool {
+			if err := s.SetRaw(ctx, other, []byte("nested"), NeverExpire); err != nil {
+				t.Errorf("nested set of %q: %v", other, err)
+			}
+			entry.Value = []byte("outer")
+			return true
+		})
+		if err != nil {
+			t.Fatalf("update with nested write of %q: %v", other, err)
+		}
+	}
+}
+
+func TestLockKeysNoDeadlock(t *testing.T) {
+
+	var locks keyLocks
+	ctx := context.Background()
+	keys := [][]byte{[]byte("x"), []byte("y"), []byte("z"), []byte("x")}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				order := [][]byte{keys[(g+i)%4], keys[(g+i+1)%4], keys[(g+i+2)%4]}
+				unlock, err := locks.lockKeysContext(ctx, order)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(locks.keys) != 0 {
+		t.Fatalf("%d locks left in the map", len(locks.keys))
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+	"github.com/patrickmn/go-cache"
+	"time"
+)
+
+// MergeRaw stores incoming if the key is still at baseVersion, otherwise stores the output of the conflict resolver,
+// the current expiration is kept, absent key has version 0, returns the new version of the key
+func (t *cacheStore) MergeRaw(ctx context.Context, key, incoming []byte, baseVersion int64) (version int64, err error) {
+
+	if err := t.checkKey(key); err != nil {
+		return 0, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return 0, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var current []byte
+	e, exp, ok := t.getEntryWithExpiration(string(key))
+	if ok {
+		current, version = e.Value, e.Version
+	}
+
+	value := incoming
+	if version != baseVersion {
+		if t.conf.ConflictResolver == nil {
+			return version, ErrVersionConflict
+		}
+		defer t.recoverCallback(&err)
+		t.inCallback(func() {
+			value = t.conf.ConflictResolver(t.userRawKey(key), current, incoming)
+		})
+		if value == nil {
+			return version, ErrVersionConflict
+		}
+	}
+
+	if err := t.validate(key, value); err != nil {
+		return version, err
+	}
+
+	if ok {
+		t.keepExpiration(key, value, exp)
+	} else {
+		t.setLocked(key, value, store.NoTTL)
+	}
+
+	if e, ok := t.getEntry(string(key)); ok {
+		version = e.Version
+	}
+	return version, nil
+}
+
+// keepExpiration replaces the value under the key lock without changing the expiration
+func (t *cacheStore) keepExpiration(key, value []byte, expiration int64) {
+	ttl := cache.NoExpiration
+	now := time.Now().UnixNano()
+	if expiration > 0 {
+		ttl = time.Duration(expiration - now)
+		if ttl <= 0 {
+			ttl = time.Nanosecond
+		}
+	}
+	t.setItem(string(key), t.newEntry(value), ttl)
+	t.stats.set()
+	t.notify(key, value, remainingSeconds(expiration, now))
+}
+
+// UpdateWithRetry runs the optimistic loop: reads the value with its version, applies mutate and stores the result
+// by CompareAndSetRaw keeping the remaining ttl, retries on version conflict up to maxRetries times with a growing backoff,
+// current is nil for the absent key, the mutate error is returned as is
+func (t *cacheStore) UpdateWithRetry(ctx context.Context, key []byte, maxRetries int, mutate func(current []byte) ([]byte, error)) error {
+
+	backoff := time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+
+		var (
+			ttlSeconds int
+			version    int64
+		)
+		current, err := t.GetRaw(ctx, key, &ttlSeconds, &version, false)
+		if err != nil {
+			return err
+		}
+		if current != nil && ttlSeconds == store.NoTTL {
+			ttlSeconds = NeverExpire
+		}
+
+		value, err := mutate(current)
+		if err != nil {
+			return err
+		}
+
+		ok, err := t.CompareAndSetRaw(ctx, key, value, ttlSeconds, version)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		if attempt >= maxRetries {
+			return ErrRetriesExhausted
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff < 100*time.Millisecond {
+			backoff *= 2
+		}
+	}
+}
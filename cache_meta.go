@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+	"time"
+)
+
+// EntryMeta is the metadata of the entry passed beside store.RawEntry, which has no room for it
+type EntryMeta struct {
+	// the key was removed, reported by EnumerateSinceMeta with WithSoftDelete, the value is nil
+	Tombstone bool
+	// set by SetRawTyped, DefaultContentType or empty otherwise
+	ContentType string
+	// zero for values put into the cache bypassing the store
+	Created time.Time
+}
+
+func (t *cacheStore) meta(e *entry) EntryMeta {
+	var meta EntryMeta
+	meta.ContentType = t.contentType(e)
+	if e.Created != 0 {
+		meta.Created = time.Unix(0, e.Created)
+	}
+	return meta
+}
+
+// GetRawMeta returns the value and its metadata, os.ErrNotExist if absent
+func (t *cacheStore) GetRawMeta(ctx context.Context, key []byte) ([]byte, EntryMeta, error) {
+	e, err := t.getRawEntry(ctx, key, true)
+	if err != nil {
+		return nil, EntryMeta{}, err
+	}
+	return e.Value, t.meta(e), nil
+}
+
+// EnumerateRawMeta is EnumerateRaw passing the metadata of every entry
+func (t *cacheStore) EnumerateRawMeta(ctx context.Context, prefix, seek []byte, onlyKeys bool, reverse bool, cb func(entry *store.RawEntry, meta EntryMeta) bool) (err error) {
+	defer t.recoverCallback(&err)
+	walk := t.walkForward
+	if reverse {
+		walk = t.walkReverse
+	}
+	return walk(prefix, seek, onlyKeys, func(entry *store.RawEntry, e *entry) bool {
+		return cb(entry, t.meta(e))
+	})
+}
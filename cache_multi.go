@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+)
+
+// MultiSetRawIfAbsent writes all entries only if none of their keys exists, all keys are locked for the check
+// and the writes, so either every entry is stored or none, returns whether the entries were stored
+func (t *cacheStore) MultiSetRawIfAbsent(ctx context.Context, entries []store.RawEntry) (bool, error) {
+
+	keys := make([][]byte, len(entries))
+	for i := range entries {
+		if err := t.checkKey(entries[i].Key); err != nil {
+			return false, err
+		}
+		keys[i] = t.nsKey(entries[i].Key)
+		if err := t.validate(keys[i], entries[i].Value); err != nil {
+			return false, err
+		}
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(nil)
+
+	unlock, err := t.lockKeysContext(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	for _, key := range keys {
+		if _, ok := t.cache.Get(string(key)); ok {
+			return false, nil
+		}
+	}
+
+	for i := range entries {
+		t.setLocked(keys[i], entries[i].Value, entries[i].Ttl)
+	}
+	return true, nil
+}
+
+// MultiCompareAndSetRaw is the optimistic multi-key transaction, all keys are locked, every entry Version must match
+// the current version of its key (absent key has version 0) and only then all values are written with new versions,
+// returns false without writing anything if any version differs
+func (t *cacheStore) MultiCompareAndSetRaw(ctx context.Context, entries []store.RawEntry) (bool, error) {
+
+	keys := make([][]byte, len(entries))
+	for i := range entries {
+		if err := t.checkKey(entries[i].Key); err != nil {
+			return false, err
+		}
+		keys[i] = t.nsKey(entries[i].Key)
+		if err := t.validate(keys[i], entries[i].Value); err != nil {
+			return false, err
+		}
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(nil)
+
+	unlock, err := t.lockKeysContext(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	for i, key := range keys {
+		var current int64
+		if e, ok := t.getEntry(string(key)); ok {
+			current = e.Version
+		}
+		if current != entries[i].Version {
+			return false, nil
+		}
+	}
+
+	for i := range entries {
+		t.setLocked(keys[i], entries[i].Value, entries[i].Ttl)
+	}
+	return true, nil
+}
+
+// BatchIncrementRaw adds every delta to its counter under the locks of all keys and returns the new values,
+// concurrent multi-key operations and MultiGetRaw observe the group either before or after the whole batch,
+// plain GetRaw does not lock and may see a part of it
+func (t *cacheStore) BatchIncrementRaw(ctx context.Context, deltas map[string]int64, ttlSeconds int) (map[string]int64, error) {
+
+	keys := make([][]byte, 0, len(deltas))
+	for key := range deltas {
+		if err := t.checkKey([]byte(key)); err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.nsKey([]byte(key)))
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return nil, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(nil)
+
+	unlock, err := t.lockKeysContext(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	values := make(map[string]int64, len(deltas))
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		var counter int64
+		if e, ok := t.getEntry(string(key)); ok {
+			counter = decodeCounter(e.Value)
+		}
+		counter += deltas[t.userKey(string(key))]
+		values[t.userKey(string(key))] = counter
+		encoded[i] = encodeCounter(counter)
+		if err := t.validate(key, encoded[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, key := range keys {
+		t.setLocked(key, encoded[i], ttlSeconds)
+	}
+	return values, nil
+}
+
+// MultiGetRaw reads the keys under their locks, so writes made by the multi-key operations are seen whole,
+// the value is nil for the missing key
+func (t *cacheStore) MultiGetRaw(ctx context.Context, keys [][]byte) ([][]byte, error) {
+
+	nsKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		if err := t.checkKey(key); err != nil {
+			return nil, err
+		}
+		nsKeys[i] = t.nsKey(key)
+	}
+
+	unlock, err := t.lockKeysContext(ctx, nsKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range nsKeys {
+		if e := t.getEntryImpl(key); e != nil {
+			values[i] = e.Value
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"github.com/patrickmn/go-cache"
+	"strings"
+	"sync"
+)
+
+// nsKey maps the key of the caller to the key in the cache, applied once at the entry point of every operation
+func (t *cacheStore) nsKey(key []byte) []byte {
+	ns := t.conf.Namespace
+	if ns == "" {
+		return key
+	}
+	k := make([]byte, len(ns)+len(key))
+	copy(k, ns)
+	copy(k[len(ns):], key)
+	return k
+}
+
+// userKey maps the key in the cache back to the key of the caller
+func (t *cacheStore) userKey(key string) string {
+	return key[len(t.conf.Namespace):]
+}
+
+// userRawKey is userKey for byte keys, shares the memory of key
+func (t *cacheStore) userRawKey(key []byte) []byte {
+	return key[len(t.conf.Namespace):]
+}
+
+// ownsKey reports whether the key in the cache belongs to this store, userKey must be given only such keys
+func (t *cacheStore) ownsKey(key string) bool {
+	return strings.HasPrefix(key, t.conf.Namespace)
+}
+
+// items returns the snapshot of the live items of this store only
+func (t *cacheStore) items() map[string]cache.Item {
+	items := t.cache.Items()
+	if t.conf.Namespace != "" {
+		for key := range items {
+			if !t.ownsKey(key) {
+				delete(items, key)
+			}
+		}
+	}
+	return items
+}
+
+// itemCount counts the items of this store, a scan when the cache is shared by namespaces
+func (t *cacheStore) itemCount() int {
+	if t.conf.Namespace == "" {
+		return t.cache.ItemCount()
+	}
+	return len(t.items())
+}
+
+// evictionDispatch passes the evictions of the cache to the store owning the key, go-cache keeps a single
+// eviction callback and the stores sharing the cache by namespaces need one each
+type evictionDispatch struct {
+	mu     sync.Mutex
+	stores []*cacheStore
+}
+
+// dispatches of the caches with attached stores, removed with the last store
+var dispatches = struct {
+	sync.Mutex
+	caches map[*cache.Cache]*evictionDispatch
+}{caches: make(map[*cache.Cache]*evictionDispatch)}
+
+// attachEvicted routes the evictions of the store namespace to the handle, installs the dispatch on the first store
+func attachEvicted(c *cache.Cache, t *cacheStore) {
+	dispatches.Lock()
+	defer dispatches.Unlock()
+	d, ok := dispatches.caches[c]
+	if !ok {
+		d = &evictionDispatch{}
+		dispatches.caches[c] = d
+		c.OnEvicted(d.onEvicted)
+	}
+	d.mu.Lock()
+	d.stores = append(d.stores, t)
+	d.mu.Unlock()
+}
+
+// detachEvicted stops the evictions to the store, the last store leaves the cache without eviction callback
+func (t *cacheStore) detachEvicted() {
+	dispatches.Lock()
+	defer dispatches.Unlock()
+	d, ok := dispatches.caches[t.cache]
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	for i, s := range d.stores {
+		if s.storeState == t.storeState {
+			d.stores = append(d.stores[:i:i], d.stores[i+1:]...)
+			break
+		}
+	}
+	empty := len(d.stores) == 0
+	d.mu.Unlock()
+	if empty {
+		delete(dispatches.caches, t.cache)
+		t.cache.OnEvicted(nil)
+	}
+}
+
+// onEvicted picks the store with the longest namespace matching the key, the latest one among equal namespaces,
+// and calls it outside of the lock as the store callback may delete keys of the cache
+func (d *evictionDispatch) onEvicted(key string, value interface{}) {
+	var owner *cacheStore
+	d.mu.Lock()
+	for _, s := range d.stores {
+		if s.ownsKey(key) && (owner == nil || len(s.conf.Namespace) >= len(owner.conf.Namespace)) {
+			owner = s
+		}
+	}
+	d.mu.Unlock()
+	if owner != nil {
+		owner.onEvicted(key, value)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestInstanceOpsNamespace(t *testing.T) {
+
+	s := New("test", WithNamespace("ns/"))
+	defer s.Destroy()
+	ops := s.InstanceOps()
+
+	if err := ops.Set("k", []byte("v"), NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := ops.Get("k"); !ok || string(value) != "v" {
+		t.Fatalf("Get after Set = %q, %v", value, ok)
+	}
+	if items := ops.Items(); len(items) != 1 || string(items["k"]) != "v" {
+		t.Fatalf("Items = %v", items)
+	}
+	if err := ops.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ops.Get("k"); ok {
+		t.Fatal("Get after Delete found the key")
+	}
+}
+
+func TestInvalidateTagNamespace(t *testing.T) {
+
+	s := New("test", WithNamespace("ns/"))
+	defer s.Destroy()
+	ctx := context.Background()
+
+	s.SetRawTagged(ctx, []byte("a"), []byte("1"), NeverExpire, "t")
+	s.SetRawTagged(ctx, []byte("b"), []byte("2"), NeverExpire, "t")
+	s.SetRaw(ctx, []byte("c"), []byte("3"), NeverExpire)
+
+	if n := s.InvalidateTag("t"); n != 2 {
+		t.Fatalf("InvalidateTag removed %d, want 2", n)
+	}
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true} {
+		if value, _ := s.GetRaw(ctx, []byte(key), nil, nil, false); (value != nil) != want {
+			t.Errorf("key %s present %v, want %v", key, value != nil, want)
+		}
+	}
+}
+
+func TestSharedCacheForeignKeysEvicted(t *testing.T) {
+
+	c := cache.New(cache.NoExpiration, 0)
+	policy := func(key, value []byte) (bool, time.Duration) {
+		return true, time.Minute
+	}
+	s := FromCache("test", c, WithNamespace("namespace/"), WithExpirationPolicy(policy), WithPrefixBloom(4, 64))
+	defer s.Destroy()
+	ctx := context.Background()
+
+	c.Set("x", []byte("foreign"), time.Millisecond)
+	s.SetRaw(ctx, []byte("k"), []byte("v"), 1)
+	time.Sleep(5 * time.Millisecond)
+
+	s.SweepExpired()
+
+	if _, ok := c.Get("x"); ok {
+		t.Fatal("foreign key was re-armed by the policy of the store")
+	}
+	if value, _ := s.GetRaw(ctx, []byte("k"), nil, nil, true); string(value) != "v" {
+		t.Fatalf("own key = %q", value)
+	}
+}
+
+func TestSharedCacheEvictionsPerNamespace(t *testing.T) {
+
+	c := cache.New(cache.NoExpiration, 0)
+	keep := func(key, value []byte) (bool, time.Duration) {
+		return true, time.Minute
+	}
+	first := FromCache("first", c, WithNamespace("first/"), WithExpirationPolicy(keep))
+	defer first.Destroy()
+	nested := FromCache("nested", c, WithNamespace("first/nested/"), WithExpirationPolicy(keep))
+	defer nested.Destroy()
+	last := FromCache("last", c, WithNamespace("last/"))
+	ctx := context.Background()
+
+	for _, s := range []*cacheStore{first, nested, last} {
+		s.SetRaw(ctx, []byte("k"), []byte(s.name), 1)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	c.DeleteExpired()
+
+	// every store applies its own expiration policy to its own keys
+	for _, s := range []*cacheStore{first, nested} {
+		if value, _ := s.GetRaw(ctx, []byte("k"), nil, nil, true); string(value) != s.name {
+			t.Errorf("store %s: key = %q, want it kept by its policy", s.name, value)
+		}
+	}
+	if value, _ := last.GetRaw(ctx, []byte("k"), nil, nil, false); value != nil {
+		t.Errorf("store last: expired key = %q", value)
+	}
+
+	// the callback goes with the last store
+	last.Destroy()
+	first.Destroy()
+	nested.Destroy()
+	if _, ok := dispatches.caches[c]; ok {
+		t.Error("dispatch of the cache outlived its stores")
+	}
+}
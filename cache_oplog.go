@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// OpRecord is one operation kept by WithOperationLog
+type OpRecord struct {
+	// increasing sequence number of the operation
+	Seq uint64
+	// get, set, update, cas, touch or remove
+	Op  string
+	Key string
+	At  time.Time
+	// hit, miss, ok, conflict or the error text
+	Result string
+}
+
+// ring buffer of the recent operations, writers claim the slot by the atomic sequence and never wait for each other
+type opLog struct {
+	seq   uint64
+	slots []atomic.Value
+}
+
+func (t *opLog) enabled() bool {
+	return len(t.slots) > 0
+}
+
+func (t *opLog) record(op string, key []byte, result string) {
+	seq := atomic.AddUint64(&t.seq, 1)
+	t.slots[seq%uint64(len(t.slots))].Store(OpRecord{
+		Seq:    seq,
+		Op:     op,
+		Key:    string(key),
+		At:     time.Now(),
+		Result: result,
+	})
+}
+
+// logOp is deferred by the operations with the key given by the caller, err points to the result of the operation
+func (t *cacheStore) logOp(op string, key []byte, result string, err *error) {
+	if !t.ops.enabled() {
+		return
+	}
+	if *err != nil {
+		result = (*err).Error()
+	}
+	t.ops.record(op, key, result)
+}
+
+// RecentOps returns the operations kept by WithOperationLog, the oldest first, nil when the log is disabled
+func (t *cacheStore) RecentOps() []OpRecord {
+	if !t.ops.enabled() {
+		return nil
+	}
+	list := make([]OpRecord, 0, len(t.ops.slots))
+	for i := range t.ops.slots {
+		if r, ok := t.ops.slots[i].Load().(OpRecord); ok {
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Seq < list[j].Seq
+	})
+	return list
+}
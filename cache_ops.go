@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+)
+
+// CacheOps is the minimal set of cache operations for advanced users,
+// every call goes through the store bookkeeping instead of the raw cache.
+type CacheOps interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttlSeconds int) error
+	Delete(key string) error
+	Items() map[string][]byte
+}
+
+type cacheOps struct {
+	t *cacheStore
+}
+
+// InstanceOps returns the safe alternative to Instance()
+func (t *cacheStore) InstanceOps() CacheOps {
+	return cacheOps{t: t}
+}
+
+func (o cacheOps) Get(key string) ([]byte, bool) {
+	value, err := o.t.GetRaw(context.Background(), []byte(key), nil, nil, true)
+	return value, err == nil
+}
+
+func (o cacheOps) Set(key string, value []byte, ttlSeconds int) error {
+	return o.t.SetRaw(context.Background(), []byte(key), value, ttlSeconds)
+}
+
+func (o cacheOps) Delete(key string) error {
+	return o.t.RemoveRaw(context.Background(), []byte(key))
+}
+
+func (o cacheOps) Items() map[string][]byte {
+	items := make(map[string][]byte)
+	o.t.doEnumerateRaw(nil, nil, 0, false, func(entry *store.RawEntry) bool {
+		items[string(entry.Key)] = entry.Value
+		return true
+	})
+	return items
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// heap checks read the memory stats, which stops the world for a moment, so they run once a second
+const memoryPressureInterval = time.Second
+
+func (t *cacheStore) runPressureSweeper(targetHeapMB uint64) {
+	t.pressure.start(memoryPressureInterval, func() {
+		t.relieveMemory(targetHeapMB << 20)
+	})
+}
+
+// relieveMemory evicts the oldest entries when the heap is above the target, it does not force the collection, so the number
+// of entries is picked by the estimate of bytes held by keys and values that covers the excess, pinned keys are kept
+func (t *cacheStore) relieveMemory(target uint64) {
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapAlloc <= target {
+		return
+	}
+	excess := int64(ms.HeapAlloc - target)
+
+	type candidate struct {
+		key     string
+		created int64
+		size    int64
+	}
+
+	var list []candidate
+	for key, item := range t.items() {
+		if e, ok := toEntry(item.Object); ok && !t.isPinned(key) {
+			list = append(list, candidate{key: key, created: e.Created, size: int64(len(key) + len(e.Value))})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].created < list[j].created
+	})
+
+	for _, c := range list {
+		if excess <= 0 {
+			return
+		}
+		if t.evict(c.key) {
+			excess -= c.size
+		}
+	}
+}
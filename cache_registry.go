@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry keeps stores by their bean name for administration across all of them
+type Registry struct {
+	mu     sync.RWMutex
+	stores map[string]*cacheStore
+}
+
+// DefaultRegistry is the registry for WithRegistry(DefaultRegistry), stores are not registered unless asked to
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{stores: make(map[string]*cacheStore)}
+}
+
+// Register adds the store under its name replacing the store registered under the same name
+func (r *Registry) Register(s *cacheStore) {
+	r.mu.Lock()
+	r.stores[s.name] = s
+	r.mu.Unlock()
+}
+
+// Unregister removes the store if it is the one registered under its name
+func (r *Registry) Unregister(s *cacheStore) {
+	r.mu.Lock()
+	if r.stores[s.name] == s {
+		delete(r.stores, s.name)
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(name string) (*cacheStore, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.stores[name]
+	return s, ok
+}
+
+// List returns the names of the registered stores in ascending order
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.stores))
+	for name := range r.stores {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// DropAll drops every registered store, stores failing to drop, for example frozen ones, do not stop the others
+// and the first error is returned naming the store
+func (r *Registry) DropAll() error {
+	var first error
+	for _, name := range r.List() {
+		s, ok := r.Get(name)
+		if !ok {
+			continue
+		}
+		if err := s.DropAll(); err != nil && first == nil {
+			first = fmt.Errorf("cachestore '%s': %w", name, err)
+		}
+	}
+	return first
+}
+
+func (t *cacheStore) unregister() {
+	if t.conf.Registry != nil {
+		t.conf.Registry.Unregister(t)
+	}
+}
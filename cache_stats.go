@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"encoding/json"
+	"github.com/patrickmn/go-cache"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of the store counters
+type Stats struct {
+	Hits    int64
+	Misses  int64
+	Sets    int64
+	Removes int64
+	// events dropped because a subscriber channel was full
+	DroppedEvents int64
+	// entries evicted to keep the store within MaxEntries
+	Evictions int64
+	// failed writes to or removals from the spillover store
+	SpillErrors int64
+	Entries     int
+	// value size metrics, filled only with WithValueSizeStats, entries expired but not yet swept are included
+	ValueBytesTotal int64
+	ValueCount      int
+	// the largest value stored since the start or the last DropAll
+	MaxValueSize int
+	// cycles run by WithAutoCompact, including the skipped ones below the discard ratio
+	CompactCycles int64
+	// entries reclaimed by all cycles and by the last one
+	CompactReclaimed     int64
+	LastCompactReclaimed int64
+}
+
+// counters are updated atomically, keep int64 fields first for alignment on 32-bit platforms
+type storeStats struct {
+	hits        int64
+	misses      int64
+	sets        int64
+	removes     int64
+	dropped     int64
+	evicted     int64
+	spillErrors int64
+	compactions int64
+	reclaimed   int64
+	lastReclaim int64
+}
+
+func (t *storeStats) hit() {
+	atomic.AddInt64(&t.hits, 1)
+}
+
+func (t *storeStats) miss() {
+	atomic.AddInt64(&t.misses, 1)
+}
+
+func (t *storeStats) set() {
+	atomic.AddInt64(&t.sets, 1)
+}
+
+func (t *storeStats) remove() {
+	atomic.AddInt64(&t.removes, 1)
+}
+
+func (t *storeStats) drop() {
+	atomic.AddInt64(&t.dropped, 1)
+}
+
+func (t *storeStats) evict() {
+	atomic.AddInt64(&t.evicted, 1)
+}
+
+func (t *storeStats) spillError() {
+	atomic.AddInt64(&t.spillErrors, 1)
+}
+
+func (t *storeStats) compacted(reclaimed int64) {
+	atomic.AddInt64(&t.compactions, 1)
+	atomic.AddInt64(&t.reclaimed, reclaimed)
+	atomic.StoreInt64(&t.lastReclaim, reclaimed)
+}
+
+// valueSizes keeps the size of every stored value, a replaced value is accounted by its key
+type valueSizes struct {
+	mu    sync.Mutex
+	sizes map[string]int
+	total int64
+	max   int
+}
+
+func (t *valueSizes) put(key string, size int) {
+	t.mu.Lock()
+	if t.sizes == nil {
+		t.sizes = make(map[string]int)
+	}
+	t.total += int64(size - t.sizes[key])
+	t.sizes[key] = size
+	if size > t.max {
+		t.max = size
+	}
+	t.mu.Unlock()
+}
+
+// evicted is called from the eviction callback, the key may already hold the new value written concurrently
+func (t *valueSizes) evicted(key string, c *cache.Cache) {
+	if _, ok := c.Get(key); ok {
+		return
+	}
+	t.mu.Lock()
+	t.total -= int64(t.sizes[key])
+	delete(t.sizes, key)
+	t.mu.Unlock()
+}
+
+func (t *valueSizes) reset() {
+	t.mu.Lock()
+	t.sizes, t.total, t.max = nil, 0, 0
+	t.mu.Unlock()
+}
+
+// rebuild recounts the values put into the cache bypassing the store
+func (t *valueSizes) rebuild(items map[string]cache.Item) {
+	t.reset()
+	for key, item := range items {
+		if e, ok := toEntry(item.Object); ok {
+			t.put(key, len(e.Value))
+		}
+	}
+}
+
+func (t *valueSizes) snapshot() (total int64, count int, max int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total, len(t.sizes), t.max
+}
+
+// Stats returns the snapshot of the store counters, safe for concurrent use
+func (t *cacheStore) Stats() Stats {
+	total, count, max := t.sizes.snapshot()
+	return Stats{
+		Hits:                 atomic.LoadInt64(&t.stats.hits),
+		Misses:               atomic.LoadInt64(&t.stats.misses),
+		Sets:                 atomic.LoadInt64(&t.stats.sets),
+		Removes:              atomic.LoadInt64(&t.stats.removes),
+		DroppedEvents:        atomic.LoadInt64(&t.stats.dropped),
+		Evictions:            atomic.LoadInt64(&t.stats.evicted),
+		SpillErrors:          atomic.LoadInt64(&t.stats.spillErrors),
+		Entries:              t.itemCount(),
+		ValueBytesTotal:      total,
+		ValueCount:           count,
+		MaxValueSize:         max,
+		CompactCycles:        atomic.LoadInt64(&t.stats.compactions),
+		CompactReclaimed:     atomic.LoadInt64(&t.stats.reclaimed),
+		LastCompactReclaimed: atomic.LoadInt64(&t.stats.lastReclaim),
+	}
+}
+
+// PrefixSizes sums key and value bytes of live entries per prefix in a single pass without copying values,
+// a key matching several prefixes is counted in each of them
+func (t *cacheStore) PrefixSizes(prefixes [][]byte) map[string]int64 {
+
+	sizes := make(map[string]int64, len(prefixes))
+	for _, prefix := range prefixes {
+		sizes[string(prefix)] = 0
+	}
+
+	for key, item := range t.items() {
+		e, ok := toEntry(item.Object)
+		if !ok {
+			continue
+		}
+		key = t.userKey(key)
+		for prefix := range sizes {
+			if strings.HasPrefix(key, prefix) {
+				sizes[prefix] += int64(len(key) + len(e.Value))
+			}
+		}
+	}
+
+	return sizes
+}
+
+// StatsReport is the serializable snapshot returned by StatsJSON
+type StatsReport struct {
+	Name      string `json:"name"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Sets      int64  `json:"sets"`
+	Removes   int64  `json:"removes"`
+	Evictions int64  `json:"evictions"`
+	Entries   int    `json:"entries"`
+	// key and value bytes of the live entries
+	ApproxBytes   int64      `json:"approx_bytes"`
+	TTL           TTLSummary `json:"ttl"`
+	UptimeSeconds int64      `json:"uptime_seconds"`
+}
+
+// TTLSummary counts live entries by the remaining ttl
+type TTLSummary struct {
+	NoExpiration int `json:"no_expiration"`
+	UnderMinute  int `json:"under_minute"`
+	UnderHour    int `json:"under_hour"`
+	UnderDay     int `json:"under_day"`
+	DayOrMore    int `json:"day_or_more"`
+}
+
+func (t *TTLSummary) add(expiration, now int64) {
+	left := time.Duration(expiration - now)
+	switch {
+	case expiration <= 0:
+		t.NoExpiration++
+	case left < time.Minute:
+		t.UnderMinute++
+	case left < time.Hour:
+		t.UnderHour++
+	case left < 24*time.Hour:
+		t.UnderDay++
+	default:
+		t.DayOrMore++
+	}
+}
+
+// StatsJSON returns the counters of Stats together with the size and the ttl distribution of live entries and the uptime
+// of the store marshaled as JSON for admin endpoints, it walks all entries, so it is O(n) like PrefixSizes
+func (t *cacheStore) StatsJSON() ([]byte, error) {
+
+	stats := t.Stats()
+	report := StatsReport{
+		Name:          t.name,
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		Sets:          stats.Sets,
+		Removes:       stats.Removes,
+		Evictions:     stats.Evictions,
+		UptimeSeconds: int64(time.Since(t.started) / time.Second),
+	}
+
+	now := time.Now().UnixNano()
+	for key, item := range t.items() {
+		e, ok := toEntry(item.Object)
+		if !ok {
+			continue
+		}
+		report.Entries++
+		report.ApproxBytes += int64(len(key) - len(t.conf.Namespace) + len(e.Value))
+		report.TTL.add(item.Expiration, now)
+	}
+
+	return json.Marshal(report)
+}
@@ -6,35 +6,138 @@
 package cachestore
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"fmt"
 	"github.com/keyvalstore/store"
 	"io"
 	"os"
 	"github.com/patrickmn/go-cache"
 	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var CacheStoreClass = reflect.TypeOf((*cacheStore)(nil))
 
+// cacheStore is the handle given to callers, the state lives behind it so the background goroutines can run
+// on a handle of their own. Like the go-cache janitor, they stop when the caller handle is collected without Destroy
 type cacheStore struct {
+	*storeState
+}
+
+type storeState struct {
+	version   int64
+	stats     storeStats
 	name      string
 	cache     *cache.Cache
+	conf      *Config
+	locks     keyLocks
+	tags      tagIndex
+	subs      subscribers
+	evictMu   sync.Mutex
+	sweeper   sweeper
+	compactor sweeper
+	idler     sweeper
+	pressure  sweeper
+	verifier  sweeper
+	started   time.Time
+	deleting  deleteMarks
+	// taken before the gate by the restores, which enter the gate while holding it
+	backupMu  sync.Mutex
+	gate      freezeGate
+	tombs     tombstones
+	sizes     valueSizes
+	reaping   deleteMarks
+	expIndex  expirationIndex
+	loading   loadLocks
+	ops       opLog
+	deletes   deletions
+	bloom     prefixBloom
+	coalesce  coalescer
 }
 
 func NewDefault(name string) *cacheStore {
 	return New(name)
 }
 
+// New creates the store that sweeps expired entries itself every CleanupInterval, Destroy stops the sweeper
 func New(name string, options ...Option) *cacheStore {
-	cache := OpenDatabase(options...)
-	return &cacheStore{name: name, cache: cache}
+	conf := newConfig(options...)
+	return newStore(name, openDatabase(conf), conf, conf.CleanupInterval)
+}
+
+// FromCache wraps the existing cache and leaves cleanup to the cache janitor, the stores on the cache take over
+// its eviction callback and each store gets the evictions of its namespace.
+// The store writes its internal entries into the cache, see Instance
+func FromCache(name string, c *cache.Cache, options ...Option) *cacheStore {
+	return newStore(name, c, newConfig(options...), 0)
+}
+
+// newStore starts the background work on the handle of its own that does not keep the returned handle alive,
+// the finalizer of the returned handle stops the work
+func newStore(name string, c *cache.Cache, conf *Config, sweepInterval time.Duration) *cacheStore {
+	state := &storeState{name: name, cache: c, conf: conf, started: time.Now()}
+	t, bg := &cacheStore{state}, &cacheStore{state}
+	runtime.SetFinalizer(t, func(t *cacheStore) {
+		t.stopSweeper()
+		t.detachEvicted()
+	})
+	attachEvicted(c, bg)
+	if conf.OperationLog > 0 {
+		t.ops.slots = make([]atomic.Value, conf.OperationLog)
+	}
+	if conf.PrefixBloomCounters > 0 {
+		t.bloom = prefixBloom{prefixLen: conf.PrefixBloomLen, counters: make([]uint32, conf.PrefixBloomCounters)}
+		t.bloomRebuild(t.items())
+	}
+	if conf.ValueSizeStats {
+		t.sizes.rebuild(t.items())
+	}
+	if conf.ExpirationIndex {
+		t.expIndex.rebuild(t.items())
+	}
+	if sweepInterval > 0 {
+		bg.runSweeper(sweepInterval)
+	}
+	if conf.AutoCompactInterval > 0 {
+		bg.runCompactor(conf.AutoCompactInterval, conf.AutoCompactRatio)
+	}
+	if conf.IdleTimeout > 0 {
+		bg.runIdleSweeper(conf.IdleTimeout)
+	}
+	if conf.TargetHeapMB > 0 {
+		bg.runPressureSweeper(conf.TargetHeapMB)
+	}
+	if conf.VerifyInterval > 0 {
+		bg.runVerifier(conf.VerifyInterval)
+	}
+	if conf.Registry != nil {
+		conf.Registry.Register(t)
+	}
+	return t
 }
 
-func FromCache(name string, c *cache.Cache) *cacheStore {
-	return &cacheStore{name: name, cache: c}
+// called by go-cache on delete and on expiration cleanup, keys of other stores sharing the cache are skipped
+func (t*cacheStore) onEvicted(key string, value interface{}) {
+	if !t.ownsKey(key) {
+		return
+	}
+	if t.bloom.enabled() {
+		t.bloom.remove(t.userKey(key))
+	}
+	if t.conf.ValueSizeStats {
+		t.sizes.evicted(key, t.cache)
+	}
+	if t.tracksExpiration() && !t.deleting.has(key) {
+		t.expired(key, value)
+	}
+	t.tags.evicted(key, t.cache)
 }
 
 func (t*cacheStore) Interface() store.ManagedDataStore {
@@ -46,9 +149,24 @@ func (t*cacheStore) BeanName() string {
 }
 
 func (t*cacheStore) Destroy() error {
+	t.FlushPending()
+	t.stopSweeper()
+	t.detachEvicted()
+	t.unregister()
 	return nil
 }
 
+// Close stops the background sweepers and compactor and waits within the context deadline for the work in progress to finish,
+// they are the only background work of the store, after Close the store behaves as after Destroy.
+// Coalesced writes still pending are written first
+func (t *cacheStore) Close(ctx context.Context) error {
+	t.FlushPending()
+	t.stopSweeper()
+	t.detachEvicted()
+	t.unregister()
+	return t.waitSweeper(ctx)
+}
+
 func (t*cacheStore) Get(ctx context.Context) *store.GetOperation {
 	return &store.GetOperation{DataStore: t, Context: ctx}
 }
@@ -77,21 +195,246 @@ func (t*cacheStore) Enumerate(ctx context.Context) *store.EnumerateOperation {
 	return &store.EnumerateOperation{DataStore: t, Context: ctx}
 }
 
+// GetRaw reads synchronously from the cache. Every write is applied to the cache before the write call returns,
+// so GetRaw after SetRaw, UpdateRaw or RemoveRaw of the same key always observes that write (read-your-writes),
+// optional features must keep this guarantee for the synchronous path.
+// ttlPtr receives the remaining ttl in seconds rounded up, store.NoTTL for keys without expiration.
 func (t*cacheStore) GetRaw(ctx context.Context, key []byte, ttlPtr *int, versionPtr *int64, required bool) ([]byte, error) {
-	return t.getImpl(key, required)
+
+	e, err := t.getRawEntry(ctx, key, required)
+	if t.ops.enabled() {
+		result := "hit"
+		if e == nil {
+			result = "miss"
+		}
+		t.logOp("get", key, result, &err)
+	}
+	if e == nil {
+		return nil, err
+	}
+
+	if versionPtr != nil {
+		*versionPtr = e.Version
+	}
+
+	if ttlPtr != nil {
+		*ttlPtr = store.NoTTL
+		if _, expiration, ok := t.getEntryWithExpiration(lookupKey(t.nsKey(key))); ok {
+			*ttlPtr = remainingSeconds(expiration, time.Now().UnixNano())
+		}
+	}
+
+	return e.Value, nil
 }
 
-func (t*cacheStore) SetRaw(ctx context.Context, key, value []byte, ttlSeconds int) error {
+// FetchRaw is GetRaw returning the entry with key, value, remaining ttl and version, nil for the missing key,
+// os.ErrNotExist or ErrExpired if required. Ttl follows GetRaw, store.NoTTL for keys without expiration
+func (t *cacheStore) FetchRaw(ctx context.Context, key []byte, required bool) (*store.RawEntry, error) {
 
-	ttl := cache.NoExpiration
-	if ttlSeconds > 0 {
-		ttl = time.Second * time.Duration(ttlSeconds)
+	e, err := t.getRawEntry(ctx, key, required)
+	if t.ops.enabled() {
+		result := "hit"
+		if e == nil {
+			result = "miss"
+		}
+		t.logOp("get", key, result, &err)
+	}
+	if e == nil {
+		return nil, err
+	}
+
+	entry := &store.RawEntry{
+		Key:     key,
+		Value:   e.Value,
+		Ttl:     store.NoTTL,
+		Version: e.Version,
+	}
+	if _, expiration, ok := t.getEntryWithExpiration(lookupKey(t.nsKey(key))); ok {
+		entry.Ttl = remainingSeconds(expiration, time.Now().UnixNano())
+	}
+	return entry, nil
+}
+
+// TTLRaw returns the remaining ttl in seconds rounded up without fetching the value, NeverExpire for keys without expiration
+func (t *cacheStore) TTLRaw(ctx context.Context, key []byte) (ttl int, exists bool, err error) {
+
+	if err := t.checkKey(key); err != nil {
+		return 0, false, err
+	}
+	key = t.nsKey(key)
+
+	_, expiration, ok := t.getEntryWithExpiration(lookupKey(key))
+	if !ok {
+		return 0, false, nil
+	}
+	if expiration == 0 {
+		return NeverExpire, true, nil
+	}
+	return remainingSeconds(expiration, time.Now().UnixNano()), true, nil
+}
+
+// getRawEntry is the read path of GetRaw, returns nil entry for the missing key and the error if required
+func (t *cacheStore) getRawEntry(ctx context.Context, key []byte, required bool) (*entry, error) {
+
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	key = t.nsKey(key)
+
+	if t.coalescing() {
+		if e, ok := t.pendingEntry(string(key)); ok {
+			t.stats.hit()
+			return e, nil
+		}
+	}
+
+	e := t.getEntryImpl(key)
+	if e == nil && t.conf.Spillover != nil {
+		var err error
+		if e, err = t.promote(ctx, key); err != nil {
+			return nil, err
+		}
 	}
 
-	t.cache.Set(string(key), value, ttl)
+	if e == nil && required {
+		if t.isExpired(key) {
+			return nil, ErrExpired
+		}
+		return nil, os.ErrNotExist
+	}
+
+	return e, nil
+}
+
+// GetRawOrDefault returns the stored value or def if the key is absent or expired
+func (t *cacheStore) GetRawOrDefault(ctx context.Context, key, def []byte) []byte {
+	value, err := t.GetRaw(ctx, key, nil, nil, false)
+	if err != nil || value == nil {
+		return def
+	}
+	return value
+}
+
+// GetRangeRaw returns a copy of value[offset:offset+length] clamped to the value bounds, negative length means up to the end
+func (t *cacheStore) GetRangeRaw(ctx context.Context, key []byte, offset, length int) ([]byte, error) {
+
+	e, err := t.getRawEntry(ctx, key, true)
+	if err != nil {
+		return nil, err
+	}
+	value := e.Value
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(value) {
+		offset = len(value)
+	}
+	end := len(value)
+	if length >= 0 && length < end-offset {
+		end = offset + length
+	}
+
+	part := make([]byte, end-offset)
+	copy(part, value[offset:end])
+	return part, nil
+}
+
+// GetPrefixMap returns all live entries under the prefix keyed by the full key,
+// values are shared with the cache the same way as in GetRaw and must not be modified
+func (t *cacheStore) GetPrefixMap(ctx context.Context, prefix []byte) (map[string][]byte, error) {
+
+	prefixStr := string(t.nsKey(prefix))
+	result := make(map[string][]byte)
+
+	for key, item := range t.items() {
+		if e, ok := toEntry(item.Object); ok && strings.HasPrefix(key, prefixStr) {
+			result[t.userKey(key)] = e.Value
+		}
+	}
+
+	return result, nil
+}
+
+// Snapshot returns copies of all live entries keyed by the key, taken while the store is frozen so no write lands in the middle,
+// coalesced writes still pending are included. It copies every key and value, so it costs O(n) memory and is meant for tests
+// and inspection. Must not be called from the callback of a mutating operation
+func (t *cacheStore) Snapshot() map[string][]byte {
+
+	t.Freeze()
+	defer t.Unfreeze()
+
+	result := make(map[string][]byte)
+
+	for key, item := range t.items() {
+		if e, ok := toEntry(item.Object); ok {
+			result[t.userKey(key)] = append([]byte{}, e.Value...)
+		}
+	}
+
+	if t.coalescing() {
+		t.coalesce.mu.Lock()
+		for key, p := range t.coalesce.pending {
+			if strings.HasPrefix(key, t.conf.Namespace) {
+				result[t.userKey(key)] = append([]byte{}, p.e.Value...)
+			}
+		}
+		t.coalesce.mu.Unlock()
+	}
+
+	return result
+}
+
+// SetRaw stores the value under the key lock, the value is visible to readers once the call returns,
+// with WriteCoalescing the value is kept pending till the end of the window and GetRaw serves it in the meantime
+func (t*cacheStore) SetRaw(ctx context.Context, key, value []byte, ttlSeconds int) (err error) {
+	defer t.logOp("set", key, "ok", &err)
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.locks.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if t.coalescing() {
+		t.coalesceLocked(key, value, ttlSeconds)
+		return nil
+	}
+
+	t.setLocked(key, value, ttlSeconds)
 	return nil
 }
 
+// setLocked is called under the key lock
+func (t *cacheStore) setLocked(key, value []byte, ttlSeconds int) {
+	t.putLocked(key, t.newEntry(value), ttlSeconds)
+}
+
+func (t *cacheStore) putLocked(key []byte, e *entry, ttlSeconds int) {
+	t.setItem(string(key), e, t.expiration(ttlSeconds))
+	if t.tags.isActive() {
+		t.tags.untag(string(key))
+	}
+	t.stats.set()
+	t.notify(key, e.Value, ttlSeconds)
+}
+
 func (t *cacheStore) IncrementRaw(ctx context.Context, key []byte, initial, delta int64, ttlSeconds int) (prev int64, err error) {
 	err = t.UpdateRaw(ctx, key, func(entry *store.RawEntry) bool {
 		counter := initial
@@ -108,118 +451,803 @@ func (t *cacheStore) IncrementRaw(ctx context.Context, key []byte, initial, delt
 	return
 }
 
-func (t *cacheStore) UpdateRaw(ctx context.Context, key []byte, cb func(entry *store.RawEntry) bool) error {
+// AppendRaw atomically concatenates suffix to the existing value or starts a new one
+func (t *cacheStore) AppendRaw(ctx context.Context, key, suffix []byte, ttlSeconds int) (newLen int, err error) {
+	err = t.UpdateRaw(ctx, key, func(entry *store.RawEntry) bool {
+		value := make([]byte, len(entry.Value)+len(suffix))
+		copy(value, entry.Value)
+		copy(value[len(entry.Value):], suffix)
+		newLen = len(value)
+		entry.Value = value
+		entry.Ttl = ttlSeconds
+		return true
+	})
+	return
+}
+
+// UpdateRaw runs cb under the key lock and stores the entry it leaves unless it returns false,
+// cb may write other keys of the store but must not write the key itself, that deadlocks
+func (t *cacheStore) UpdateRaw(ctx context.Context, key []byte, cb func(entry *store.RawEntry) bool) (err error) {
+	defer t.logOp("update", key, "ok", &err)
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer t.recoverCallback(&err)
 
 	rawEntry := &store.RawEntry {
-		Key: key,
+		Key: t.userRawKey(key),
 		Ttl: store.NoTTL,
 		Version: 0,
 	}
 
-	if obj, ok := t.cache.Get(string(key)); ok && obj != nil {
-		if b, ok := obj.([]byte); ok {
-			rawEntry.Value = b
-		}
+	if e, ok := t.getEntry(string(key)); ok {
+		rawEntry.Value = e.Value
+		rawEntry.Version = e.Version
 	}
 
-	if !cb(rawEntry) {
+	var ok bool
+	t.inCallback(func() {
+		ok = cb(rawEntry)
+	})
+	if !ok {
 		return ErrCanceled
 	}
 
-	ttl := cache.NoExpiration
-	if rawEntry.Ttl > 0 {
-		ttl = time.Second * time.Duration(rawEntry.Ttl)
+	if err := t.validate(key, rawEntry.Value); err != nil {
+		return err
 	}
 
-	t.cache.Set(string(key), rawEntry.Value, ttl)
+	ttl := t.expiration(rawEntry.Ttl)
+
+	t.setItem(string(key), t.newEntry(rawEntry.Value), ttl)
+	t.stats.set()
+	t.notify(key, rawEntry.Value, rawEntry.Ttl)
 	return nil
 }
 
-func (t*cacheStore) CompareAndSetRaw(ctx context.Context, key, value []byte, ttlSeconds int, version int64) (bool, error) {
-	return true, t.SetRaw(ctx, key, value, ttlSeconds)
+// CompareAndSetRaw sets the value only if the current version of the key matches, absent key has version 0
+func (t*cacheStore) CompareAndSetRaw(ctx context.Context, key, value []byte, ttlSeconds int, version int64) (swapped bool, err error) {
+	defer func() {
+		result := "conflict"
+		if swapped {
+			result = "ok"
+		}
+		t.logOp("cas", key, result, &err)
+	}()
+
+	if err := t.checkKey(key); err != nil {
+		return false, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return false, err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	var current int64
+	if e, ok := t.getEntry(string(key)); ok {
+		current = e.Version
+	}
+
+	if current != version {
+		return false, nil
+	}
+
+	t.setLocked(key, value, ttlSeconds)
+	return true, nil
 }
 
-func (t *cacheStore) TouchRaw(ctx context.Context, key []byte, ttlSeconds int) error {
+// SetRawIfChanged writes the value only if it differs from the stored one byte by byte,
+// an identical value keeps its ttl, version and access stats and false is returned
+func (t *cacheStore) SetRawIfChanged(ctx context.Context, key, value []byte, ttlSeconds int) (bool, error) {
 
-	var value []byte
+	if err := t.checkKey(key); err != nil {
+		return false, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return false, err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if e, ok := t.getEntry(string(key)); ok && bytes.Equal(e.Value, value) {
+		return false, nil
+	}
+
+	t.setLocked(key, value, ttlSeconds)
+	return true, nil
+}
+
+// GetOrCreateRaw stores the value if the key is absent and returns it with created set, otherwise returns the existing value,
+// the check and the write happen under the key lock, so concurrent callers all get the value of the one that created it
+func (t *cacheStore) GetOrCreateRaw(ctx context.Context, key, value []byte, ttlSeconds int) (stored []byte, created bool, err error) {
+
+	if err := t.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return nil, false, err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return nil, false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	if e, ok := t.getEntry(string(key)); ok && e.Value != nil {
+		return e.Value, false, nil
+	}
+
+	t.setLocked(key, value, ttlSeconds)
+	return value, true, nil
+}
+
+// SetRawIfExpiringWithin writes the value only if the key is absent or its remaining ttl is under within,
+// keys without expiration are never overwritten, so of concurrent refreshers only the first one writes
+func (t *cacheStore) SetRawIfExpiringWithin(ctx context.Context, key, value []byte, within time.Duration, ttlSeconds int) (bool, error) {
+
+	if err := t.checkKey(key); err != nil {
+		return false, err
+	}
+	key = t.nsKey(key)
 
-	if obj, ok := t.cache.Get(string(key)); ok && obj != nil {
-		if b, ok := obj.([]byte); ok {
-			value = b
+	if err := t.validate(key, value); err != nil {
+		return false, err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if _, expiration, ok := t.getEntryWithExpiration(string(key)); ok {
+		if expiration == 0 || time.Duration(expiration-time.Now().UnixNano()) >= within {
+			return false, nil
 		}
 	}
 
-	ttl := cache.NoExpiration
-	if ttlSeconds > 0 {
-		ttl = time.Second * time.Duration(ttlSeconds)
+	t.setLocked(key, value, ttlSeconds)
+	return true, nil
+}
+
+func (t *cacheStore) TouchRaw(ctx context.Context, key []byte, ttlSeconds int) (err error) {
+	defer t.logOp("touch", key, "ok", &err)
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return err
 	}
+	defer t.exitWrite()
 
-	t.cache.Set(string(key),value, ttl)
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e, ok := t.getEntry(string(key))
+	if !ok {
+		e = t.newEntry(nil)
+	}
+
+	ttl := t.expiration(ttlSeconds)
+
+	t.setItem(string(key), e, ttl)
 	return nil
 }
 
-func (t*cacheStore) RemoveRaw(ctx context.Context, key []byte) error {
-	t.cache.Delete(string(key))
+// SetTTLRaw sets the remaining ttl of the existing key to ttlSeconds, shorter or longer than the current one,
+// unlike TouchRaw it never creates the key and returns os.ErrNotExist if absent, the configured bounds still apply
+func (t *cacheStore) SetTTLRaw(ctx context.Context, key []byte, ttlSeconds int) error {
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	obj, ok := t.cache.Get(string(key))
+	if !ok {
+		return os.ErrNotExist
+	}
+
+	ttl := t.expiration(ttlSeconds)
+	t.cache.Set(string(key), obj, ttl)
+	t.expires(string(key), ttl)
 	return nil
 }
 
-func (t*cacheStore) getImpl(key []byte, required bool) ([]byte, error) {
+// ListChildren returns the distinct next segments of live keys under the prefix up to the delimiter in ascending order,
+// for keys a/b/c, a/b/d and a/e the prefix "a/" gives b and e, the key equal to the prefix is not listed
+func (t *cacheStore) ListChildren(ctx context.Context, prefix []byte, delimiter byte) ([]string, error) {
+
+	if t.skipPrefix(prefix) {
+		return nil, nil
+	}
 
-	var val []byte
-	if obj, ok := t.cache.Get(string(key)); ok && obj != nil {
-		if b, ok := obj.([]byte); ok {
-			val = b
+	prefixStr := string(t.nsKey(prefix))
+	seen := make(map[string]bool)
+
+	for key := range t.items() {
+		if !strings.HasPrefix(key, prefixStr) || len(key) == len(prefixStr) {
+			continue
+		}
+		child := key[len(prefixStr):]
+		if i := strings.IndexByte(child, delimiter); i >= 0 {
+			child = child[:i]
 		}
+		seen[child] = true
 	}
 
-	if val == nil && required {
-		return nil, os.ErrNotExist
+	children := make([]string, 0, len(seen))
+	for child := range seen {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	return children, nil
+}
+
+// TouchPrefixRaw resets expiration of all live keys under the prefix and returns the number of touched keys
+func (t *cacheStore) TouchPrefixRaw(ctx context.Context, prefix []byte, ttlSeconds int) (int, error) {
+
+	if err := t.enterWrite(); err != nil {
+		return 0, err
+	}
+	defer t.exitWrite()
+
+	prefixStr := string(t.nsKey(prefix))
+	ttl := t.expiration(ttlSeconds)
+	cnt := 0
+
+	keys := t.items()
+	if t.coalescing() {
+		// keys with only a pending write are live too, the key lock settles them
+		for _, key := range t.pendingKeys() {
+			keys[key] = cache.Item{}
+		}
 	}
 
-	return val, nil
+	for key := range keys {
+
+		if !strings.HasPrefix(key, prefixStr) {
+			continue
+		}
+
+		unlock, err := t.lockKeyContext(ctx, []byte(key))
+		if err != nil {
+			return cnt, err
+		}
+		if obj, ok := t.cache.Get(key); ok {
+			t.cache.Set(key, obj, ttl)
+			t.expires(key, ttl)
+			cnt++
+		}
+		unlock()
+
+	}
+
+	return cnt, nil
 }
 
-func (t*cacheStore) EnumerateRaw(ctx context.Context, prefix, seek []byte, batchSize int, onlyKeys bool, reverse bool, cb func(entry *store.RawEntry) bool) error {
-	if reverse {
-		var cache []*store.RawEntry
-		err := t.doEnumerateRaw(prefix, seek, batchSize, onlyKeys, func(entry *store.RawEntry) bool {
-			cache = append(cache, entry)
-			return true
-		})
+func (t*cacheStore) RemoveRaw(ctx context.Context, key []byte) (err error) {
+	defer t.logOp("remove", key, "ok", &err)
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.deleteKey(string(key))
+	t.softDeleted(string(key))
+	t.unspill(ctx, key)
+	t.stats.remove()
+	t.notify(key, nil, store.NoTTL)
+	return nil
+}
+
+// RemoveRawExisted removes the key and reports whether it was present before deletion
+func (t *cacheStore) RemoveRawExisted(ctx context.Context, key []byte) (bool, error) {
+
+	if err := t.checkKey(key); err != nil {
+		return false, err
+	}
+
+	return t.removeExisted(ctx, t.nsKey(key))
+}
+
+// removeExisted is RemoveRawExisted of the key in the cache
+func (t *cacheStore) removeExisted(ctx context.Context, key []byte) (bool, error) {
+
+	if err := t.enterWrite(); err != nil {
+		return false, err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	_, existed := t.cache.Get(string(key))
+	t.deleteKey(string(key))
+	t.softDeleted(string(key))
+	t.unspill(ctx, key)
+	t.stats.remove()
+	t.notify(key, nil, store.NoTTL)
+	return existed, nil
+}
+
+// PopRaw returns the value and removes the key in the same locked operation, so only one of concurrent callers gets it
+func (t *cacheStore) PopRaw(ctx context.Context, key []byte) ([]byte, bool, error) {
+
+	if err := t.checkKey(key); err != nil {
+		return nil, false, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return nil, false, err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	e, _, ok := t.popLocked(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return e.Value, true, nil
+}
+
+// popLocked is called under the key lock
+func (t *cacheStore) popLocked(key []byte) (*entry, int64, bool) {
+	e, expiration, ok := t.getEntryWithExpiration(string(key))
+	if !ok {
+		return nil, 0, false
+	}
+	t.deleteKey(string(key))
+	t.softDeleted(string(key))
+	t.unspill(context.Background(), key)
+	t.stats.remove()
+	t.notify(key, nil, store.NoTTL)
+	return e, expiration, true
+}
+
+// DrainPrefixRaw removes and returns up to max live entries under the prefix in ascending key order, max <= 0 means all,
+// every key is popped under its lock, so concurrent drainers never receive the same entry,
+// entries drained before the context is done are returned together with the error
+func (t *cacheStore) DrainPrefixRaw(ctx context.Context, prefix []byte, max int) ([]store.RawEntry, error) {
+
+	if err := t.enterWrite(); err != nil {
+		return nil, err
+	}
+	defer t.exitWrite()
+
+	prefixStr := string(t.nsKey(prefix))
+
+	var keys []string
+	for key := range t.items() {
+		if strings.HasPrefix(key, prefixStr) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var list []store.RawEntry
+	for _, key := range keys {
+
+		if max > 0 && len(list) == max {
+			break
+		}
+
+		unlock, err := t.lockKeyContext(ctx, []byte(key))
 		if err != nil {
-			return err
+			return list, err
 		}
-		n := len(cache)
-		for j := n-1; j >= 0; j-- {
-			if !cb(cache[j]) {
-				break
-			}
+		e, expiration, ok := t.popLocked([]byte(key))
+		unlock()
+
+		if ok {
+			list = append(list, store.RawEntry{
+				Key:     []byte(t.userKey(key)),
+				Value:   e.Value,
+				Ttl:     remainingSeconds(expiration, time.Now().UnixNano()),
+				Version: e.Version,
+			})
 		}
+
+	}
+
+	return list, nil
+}
+
+// GetAndTouchRaw returns the value and resets its ttl in the same locked operation
+func (t *cacheStore) GetAndTouchRaw(ctx context.Context, key []byte, ttlSeconds int) ([]byte, error) {
+
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	key = t.nsKey(key)
+
+	if err := t.enterWrite(); err != nil {
+		return nil, err
+	}
+	defer t.exitWrite()
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	e := t.getEntryImpl(key)
+	if e == nil {
+		return nil, os.ErrNotExist
+	}
+
+	ttl := t.expiration(ttlSeconds)
+
+	t.setItem(string(key), e, ttl)
+	return e.Value, nil
+}
+
+// validate runs the value validator with the key of the caller, key is the key in the cache
+func (t *cacheStore) validate(key, value []byte) error {
+	if t.conf.ValueValidator == nil {
+		return nil
+	}
+	return t.conf.ValueValidator(t.userRawKey(key), value)
+}
+
+// checkKey is the guard applied at the entry point of every raw operation taking a key
+func (t*cacheStore) checkKey(key []byte) error {
+	if len(key) == 0 && t.conf.RejectEmptyKeys {
+		return ErrEmptyKey
+	}
+	if t.conf.MaxKeyLength > 0 && len(key) > t.conf.MaxKeyLength {
+		return ErrKeyTooLong
+	}
+	return nil
+}
+
+// resolve ttl in seconds or one of the sentinels to the cache expiration applying the configured bounds,
+// every raw operation goes through it so UseDefaultTTL and NeverExpire mean the same everywhere
+func (t*cacheStore) expiration(ttlSeconds int) time.Duration {
+
+	ttl := cache.NoExpiration
+	if ttlSeconds > 0 {
+		ttl = time.Second * time.Duration(ttlSeconds)
+		if ttl < t.conf.MinTTL {
+			ttl = t.conf.MinTTL
+		}
+	} else if ttlSeconds == UseDefaultTTL && t.conf.DefaultTTL > 0 {
+		ttl = t.conf.DefaultTTL
+	}
+
+	if t.conf.MaxTTL > 0 && (ttl == cache.NoExpiration || ttl > t.conf.MaxTTL) {
+		ttl = t.conf.MaxTTL
+	}
+
+	if d := t.conf.TTLRounding; d > 0 && ttl > 0 {
+		now := time.Now().UnixNano()
+		deadline := now + int64(ttl)
+		if rem := deadline % int64(d); rem != 0 {
+			deadline += int64(d) - rem
+		}
+		ttl = time.Duration(deadline - now)
+	}
+
+	return ttl
+}
+
+func (t*cacheStore) getImpl(key []byte, required bool) ([]byte, error) {
+
+	e := t.getEntryImpl(key)
+	if e == nil {
+		if required {
+			return nil, os.ErrNotExist
+		}
+		return nil, nil
+	}
+
+	return e.Value, nil
+}
+
+// getEntryImpl counts hits and misses, entries with nil value count as misses
+func (t*cacheStore) getEntryImpl(key []byte) *entry {
+
+	e, ok := t.getEntry(lookupKey(key))
+	if !ok || e.Value == nil {
+		t.stats.miss()
 		return nil
+	}
+
+	t.stats.hit()
+	if t.trackAccess() {
+		e.accessed()
+	}
+	return e
+}
+
+// EnumerateRaw is a best-effort point-in-time walk with deletion tolerance: the set of keys is taken up front,
+// a key deleted or expired during the walk is skipped and never surfaces as an entry with nil value.
+// Forward seek is the lower bound, reverse seek the upper bound, both inclusive
+func (t*cacheStore) EnumerateRaw(ctx context.Context, prefix, seek []byte, batchSize int, onlyKeys bool, reverse bool, cb func(entry *store.RawEntry) bool) (err error) {
+	defer t.recoverCallback(&err)
+	if reverse {
+		return t.doEnumerateReverse(prefix, seek, onlyKeys, cb)
 	} else {
 		return t.doEnumerateRaw(prefix, seek, batchSize, onlyKeys, cb)
 	}
 }
 
+// EnumerateRawLimited stops after limit callback invocations and reports whether more matches existed, limit <= 0 means no limit
+func (t *cacheStore) EnumerateRawLimited(ctx context.Context, prefix, seek []byte, batchSize int, onlyKeys bool, reverse bool, limit int, cb func(entry *store.RawEntry) bool) (more bool, err error) {
+	if limit <= 0 {
+		return false, t.EnumerateRaw(ctx, prefix, seek, batchSize, onlyKeys, reverse, cb)
+	}
+	cnt := 0
+	err = t.EnumerateRaw(ctx, prefix, seek, batchSize, onlyKeys, reverse, func(entry *store.RawEntry) bool {
+		if cnt == limit {
+			more = true
+			return false
+		}
+		cnt++
+		return cb(entry)
+	})
+	return
+}
+
+// EnumerateRawMaxBytes stops before the entry that would take the total length of emitted values over maxBytes
+// and reports truncated, maxBytes <= 0 means no limit
+func (t *cacheStore) EnumerateRawMaxBytes(ctx context.Context, prefix, seek []byte, batchSize int, onlyKeys bool, reverse bool, maxBytes int64, cb func(entry *store.RawEntry) bool) (truncated bool, err error) {
+	if maxBytes <= 0 {
+		return false, t.EnumerateRaw(ctx, prefix, seek, batchSize, onlyKeys, reverse, cb)
+	}
+	var total int64
+	err = t.EnumerateRaw(ctx, prefix, seek, batchSize, onlyKeys, reverse, func(entry *store.RawEntry) bool {
+		total += int64(len(entry.Value))
+		if total > maxBytes {
+			truncated = true
+			return false
+		}
+		return cb(entry)
+	})
+	return
+}
+
+// EnumerateVersionsRaw emits only key and version of matching entries, value and ttl are omitted,
+// cheap enough to diff two stores before shipping any payload
+func (t *cacheStore) EnumerateVersionsRaw(ctx context.Context, prefix, seek []byte, cb func(entry *store.RawEntry) bool) (err error) {
+	defer t.recoverCallback(&err)
+	return t.doEnumerateRaw(prefix, seek, 0, true, func(entry *store.RawEntry) bool {
+		entry.Ttl = 0
+		return cb(entry)
+	})
+}
+
+// EnumerateSince emits live entries written after sinceVersion in version order for change data capture,
+// with WithSoftDelete keys removed within the grace period are emitted as entries with nil value,
+// expired keys are not reported
+func (t *cacheStore) EnumerateSince(ctx context.Context, sinceVersion int64, cb func(entry *store.RawEntry) bool) (err error) {
+	return t.EnumerateSinceMeta(ctx, sinceVersion, func(entry *store.RawEntry, meta EntryMeta) bool {
+		return cb(entry)
+	})
+}
+
+// EnumerateSinceMeta is EnumerateSince passing the metadata of every entry, removals have Tombstone set
+func (t *cacheStore) EnumerateSinceMeta(ctx context.Context, sinceVersion int64, cb func(entry *store.RawEntry, meta EntryMeta) bool) (err error) {
+	defer t.recoverCallback(&err)
+
+	type change struct {
+		entry store.RawEntry
+		meta  EntryMeta
+	}
+
+	var list []change
+	for key, item := range t.items() {
+		if e, ok := toEntry(item.Object); ok && e.Version > sinceVersion {
+			list = append(list, change{
+				entry: store.RawEntry{
+					Key:     []byte(t.userKey(key)),
+					Value:   e.Value,
+					Ttl:     remainingSeconds(item.Expiration, time.Now().UnixNano()),
+					Version: e.Version,
+				},
+				meta: t.meta(e),
+			})
+		}
+	}
+
+	if t.conf.SoftDeleteGrace > 0 {
+		for key, version := range t.deletes.since(sinceVersion, time.Now().UnixNano()) {
+			list = append(list, change{
+				entry: store.RawEntry{
+					Key:     []byte(t.userKey(key)),
+					Ttl:     store.NoTTL,
+					Version: version,
+				},
+				meta: EntryMeta{Tombstone: true},
+			})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].entry.Version < list[j].entry.Version
+	})
+
+	deadline := t.enumDeadline()
+	for i := range list {
+		if err := enumOverdue(deadline, i); err != nil {
+			return err
+		}
+		if !cb(&list[i].entry, list[i].meta) {
+			break
+		}
+	}
+	return nil
+}
+
+// EnumerateRawBatched invokes cb once per batchSize entries plus a final partial batch, batchSize <= 0 means one entry per batch,
+// the batch slice is not reused between calls
+func (t *cacheStore) EnumerateRawBatched(ctx context.Context, prefix, seek []byte, batchSize int, cb func(batch []store.RawEntry) bool) (err error) {
+	defer t.recoverCallback(&err)
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	batch := make([]store.RawEntry, 0, batchSize)
+	stopped := false
+	err = t.doEnumerateRaw(prefix, seek, batchSize, false, func(entry *store.RawEntry) bool {
+		batch = append(batch, *entry)
+		if len(batch) < batchSize {
+			return true
+		}
+		full := batch
+		batch = make([]store.RawEntry, 0, batchSize)
+		stopped = !cb(full)
+		return !stopped
+	})
+	if err == nil && !stopped && len(batch) > 0 {
+		cb(batch)
+	}
+	return err
+}
+
+// forward enumeration walks the snapshot of keys and fetches every entry right before the callback,
+// keys removed after the snapshot are skipped, keys updated after it are emitted with the current value
 func (t*cacheStore) doEnumerateRaw(prefix, seek []byte, batchSize int, onlyKeys bool, cb func(entry *store.RawEntry) bool) error {
+	return t.walkForward(prefix, seek, onlyKeys, func(entry *store.RawEntry, e *entry) bool {
+		return cb(entry)
+	})
+}
 
-	prefixStr := string(prefix)
-	seekStr := string(seek)
+func (t*cacheStore) walkForward(prefix, seek []byte, onlyKeys bool, cb func(entry *store.RawEntry, e *entry) bool) error {
 
-	for key, item := range t.cache.Items() {
+	if t.skipPrefix(prefix) {
+		return nil
+	}
 
-		if val, ok := item.Object.([]byte); ok && strings.HasPrefix(key, prefixStr) && key >= seekStr {
-			re := store.RawEntry{
-				Key:     []byte(key),
-				Ttl:     int(item.Expiration),
-				Version: item.Expiration,
-			}
-			if !onlyKeys {
-				re.Value = val
-			}
-			if !cb(&re) {
-				break
-			}
+	prefixStr := string(t.nsKey(prefix))
+	seekStr := string(t.nsKey(seek))
+	deadline := t.enumDeadline()
+	emitted := 0
+
+	for key := range t.items() {
+
+		if !strings.HasPrefix(key, prefixStr) || key < seekStr {
+			continue
+		}
+
+		e, expiration, ok := t.getEnumEntry(key)
+		if !ok {
+			continue
+		}
+
+		re := store.RawEntry{
+			Key:     []byte(t.userKey(key)),
+			Ttl:     remainingSeconds(expiration, time.Now().UnixNano()),
+			Version: e.Version,
+		}
+		if !onlyKeys {
+			re.Value = e.Value
+		}
+		if err := enumOverdue(deadline, emitted); err != nil {
+			return err
+		}
+		emitted++
+		if !cb(&re, e) {
+			break
 		}
 
 	}
@@ -227,32 +1255,195 @@ func (t*cacheStore) doEnumerateRaw(prefix, seek []byte, batchSize int, onlyKeys
 	return nil
 }
 
+// reverse enumeration sorts only the matching keys in descending order and fetches values lazily,
+// seek is the upper bound, so the walk starts at the greatest key <= seek, empty seek starts at the last key,
+// keys removed after the snapshot are skipped
+func (t*cacheStore) doEnumerateReverse(prefix, seek []byte, onlyKeys bool, cb func(entry *store.RawEntry) bool) error {
+	return t.walkReverse(prefix, seek, onlyKeys, func(entry *store.RawEntry, e *entry) bool {
+		return cb(entry)
+	})
+}
+
+func (t*cacheStore) walkReverse(prefix, seek []byte, onlyKeys bool, cb func(entry *store.RawEntry, e *entry) bool) error {
+
+	if t.skipPrefix(prefix) {
+		return nil
+	}
+
+	prefixStr := string(t.nsKey(prefix))
+	seekStr := string(t.nsKey(seek))
+	deadline := t.enumDeadline()
+	emitted := 0
+
+	var keys []string
+	for key, item := range t.items() {
+		if _, ok := t.enumEntry(item.Object); ok && strings.HasPrefix(key, prefixStr) && (len(seek) == 0 || key <= seekStr) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	for _, key := range keys {
+
+		e, expiration, ok := t.getEnumEntry(key)
+		if !ok {
+			continue
+		}
+
+		re := store.RawEntry{
+			Key:     []byte(t.userKey(key)),
+			Ttl:     remainingSeconds(expiration, time.Now().UnixNano()),
+			Version: e.Version,
+		}
+		if !onlyKeys {
+			re.Value = e.Value
+		}
+		if err := enumOverdue(deadline, emitted); err != nil {
+			return err
+		}
+		emitted++
+		if !cb(&re, e) {
+			break
+		}
+
+	}
+
+	return nil
+}
+
+// enumDeadline is the end of the enumeration started now by MaxEnumerationDuration, zero for no limit
+func (t *cacheStore) enumDeadline() time.Time {
+	if t.conf.MaxEnumerationDuration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(t.conf.MaxEnumerationDuration)
+}
+
+// enumOverdue is checked before every callback, the error tells how many entries were emitted before the cap
+func enumOverdue(deadline time.Time, emitted int) error {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return fmt.Errorf("%w, %d entries emitted", ErrEnumerationTimeout, emitted)
+	}
+	return nil
+}
+
 func (t*cacheStore) Compact(discardRatio float64) error {
-	t.cache.DeleteExpired()
+	t.SweepExpired()
 	return nil
 }
 
+// Backup writes the gob encoded snapshot of live entries in the go-cache Save format with the store entries as objects,
+// the snapshot is taken first so writers are not blocked by a slow destination. The objects are of the internal entry type
+// registered with gob by this package, so go-cache Load reads the backup only in a program importing it, see Instance
 func (t*cacheStore) Backup(w io.Writer, since uint64) (uint64, error) {
-	return 0, t.cache.Save(w)
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	return 0, saveItems(w, t.items())
 }
 
 func (t*cacheStore) Restore(src io.Reader) error {
-	return t.cache.Load(src)
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	if err := t.cache.Load(src); err != nil {
+		return err
+	}
+
+	items := t.items()
+	if t.bloom.enabled() {
+		t.bloomRebuild(items)
+	}
+	if t.conf.ValueSizeStats {
+		t.sizes.rebuild(items)
+	}
+	if t.conf.ExpirationIndex {
+		t.expIndex.rebuild(items)
+	}
+
+	for _, item := range items {
+		if e, ok := item.Object.(*entry); ok {
+			t.advanceVersion(e.Version)
+		}
+	}
+
+	return nil
 }
 
+// DropAll removes all entries except the pinned ones, it waits for in-flight writes and holds off new ones while it runs,
+// so a concurrent write is either dropped as a whole or lands after the drop. Must not be called from the callback of a mutating operation
 func (t*cacheStore) DropAll() error {
-	t.cache.Flush()
+
+	if err := t.enterExclusive(); err != nil {
+		return err
+	}
+	defer t.exitExclusive()
+
+	if t.coalescing() {
+		t.discardPending(func(key string) bool {
+			return strings.HasPrefix(key, t.conf.Namespace) && !t.isPinned(key)
+		})
+	}
+
+	if len(t.conf.PinnedPrefixes) == 0 && t.conf.Namespace == "" {
+		t.cache.Flush()
+		t.tags.reset()
+		t.sizes.reset()
+		t.expIndex.reset()
+		t.tombs.reset()
+		t.deletes.reset()
+		t.bloom.reset()
+		return nil
+	}
+
+	for key := range t.items() {
+		if !t.isPinned(key) {
+			t.deleteKey(key)
+		}
+	}
+
 	return nil
 }
 
+// isPinned takes the key in the cache, pinned prefixes are matched against the key of the caller
+func (t*cacheStore) isPinned(key string) bool {
+	key = t.userKey(key)
+	for _, prefix := range t.conf.PinnedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DropWithPrefix removes the entries under the prefix, atomic with respect to concurrent writes like DropAll
 func (t*cacheStore) DropWithPrefix(prefix []byte) error {
 
-	prefixStr := string(prefix)
+	if err := t.enterExclusive(); err != nil {
+		return err
+	}
+	defer t.exitExclusive()
+
+	prefixStr := string(t.nsKey(prefix))
 
-	for key, _ := range t.cache.Items() {
+	if t.coalescing() {
+		t.discardPending(func(key string) bool {
+			return strings.HasPrefix(key, prefixStr)
+		})
+	}
+
+	for key, _ := range t.items() {
 
 		if strings.HasPrefix(key, prefixStr){
-			t.cache.Delete(key)
+			t.deleteKey(key)
 		}
 
 	}
@@ -261,6 +1452,22 @@ func (t*cacheStore) DropWithPrefix(prefix []byte) error {
 
 }
 
+// Instance returns the underlying *cache.Cache and is kept for backward compatibility.
+// Writes made through it bypass the store bookkeeping (locks and any derived state),
+// prefer InstanceOps() for direct access.
+//
+// Breaking change: values written by the store are held as an internal entry type carrying the version,
+// creation time and content type next to the value, not as []byte. Readers that type-assert []byte on objects
+// of the cache must use ValueOf instead. Plain []byte values put into the cache directly are still read by the store.
+// Keeping []byte would need a side map keyed like the cache for the metadata, kept in sync on every write and
+// eviction, which doubles the bookkeeping and can drift on writes that bypass the store.
 func (t*cacheStore) Instance() interface{} {
 	return t.cache
 }
+
+// Config returns a copy of the resolved configuration the store was created with, changing it has no effect on the store
+func (t *cacheStore) Config() Config {
+	conf := *t.conf
+	conf.PinnedPrefixes = append([]string(nil), t.conf.PinnedPrefixes...)
+	return conf
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/keyvalstore/store"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetRangeRaw(t *testing.T) {
+
+	s := New("test")
+	defer s.Destroy()
+	ctx := context.Background()
+	s.SetRaw(ctx, []byte("k"), []byte("0123456789"), NeverExpire)
+
+	tests := []struct {
+		offset, length int
+		want           string
+	}{
+		{0, 4, "0123"},
+		{3, 2, "34"},
+		{8, 5, "89"},
+		{-2, 3, "012"},
+		{12, 3, ""},
+		{2, -1, "23456789"},
+		{1, math.MaxInt, "123456789"},
+		{math.MaxInt, math.MaxInt, ""},
+	}
+	for _, tt := range tests {
+		part, err := s.GetRangeRaw(ctx, []byte("k"), tt.offset, tt.length)
+		if err != nil || string(part) != tt.want {
+			t.Errorf("GetRangeRaw(%d, %d) = %q, %v, want %q", tt.offset, tt.length, part, err, tt.want)
+		}
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+
+	spill := New("spill")
+	defer spill.Destroy()
+
+	cases := map[string][]Option{
+		"plain":             nil,
+		"write coalescing":  {WithWriteCoalescing(time.Hour)},
+		"ttl rounding":      {WithTTLRounding(time.Minute)},
+		"namespace":         {WithNamespace("ns:")},
+		"soft delete":       {WithSoftDelete(time.Hour)},
+		"tombstones":        {WithExpiredTombstones(time.Hour)},
+		"max entries":       {WithMaxEntries(2), WithSpillover(spill)},
+		"sampled eviction":  {WithMaxEntries(2), WithEvictionSampleSize(2)},
+		"access tracking":   {WithAccessTracking(), WithIdleEviction(time.Hour)},
+		"expiration index":  {WithExpirationIndex(), WithCleanupInterval(time.Millisecond)},
+		"prefix bloom":      {WithPrefixBloom(2, 64)},
+		"operation log":     {WithOperationLog(8)},
+		"value size stats":  {WithValueSizeStats()},
+		"strict values":     {WithStrictByteValues(true)},
+		"json codec":        {WithJSONCodec()},
+		"panic recovery":    {WithPanicRecovery()},
+		"conflict resolver": {WithConflictResolver(func(key, current, incoming []byte) []byte { return incoming })},
+	}
+
+	for name, options := range cases {
+		options := options
+		t.Run(name, func(t *testing.T) {
+			s := New("test", options...)
+			defer s.Destroy()
+			ctx := context.Background()
+			for i := 0; i < 20; i++ {
+				key := []byte(fmt.Sprintf("key%d", i%4))
+				value := []byte(fmt.Sprintf("value%d", i))
+				if err := s.SetRaw(ctx, key, value, 60); err != nil {
+					t.Fatalf("SetRaw: %v", err)
+				}
+				if got, err := s.GetRaw(ctx, key, nil, nil, true); err != nil || !bytes.Equal(got, value) {
+					t.Fatalf("GetRaw after SetRaw = %q, %v, want %q", got, err, value)
+				}
+				if got, err := s.GetRangeRaw(ctx, key, 1, -1); err != nil || !bytes.Equal(got, value[1:]) {
+					t.Fatalf("GetRangeRaw after SetRaw = %q, %v, want %q", got, err, value[1:])
+				}
+				if i%5 == 4 {
+					if err := s.RemoveRaw(ctx, key); err != nil {
+						t.Fatalf("RemoveRaw: %v", err)
+					}
+					if got, _ := s.GetRaw(ctx, key, nil, nil, false); got != nil {
+						t.Fatalf("GetRaw after RemoveRaw = %q", got)
+					}
+					if got, err := s.GetRangeRaw(ctx, key, 0, -1); err == nil {
+						t.Fatalf("GetRangeRaw after RemoveRaw = %q", got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEnumerateRawReversePagination(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		keys     []string
+		pageSize int
+	}{
+		{"empty", nil, 3},
+		{"single", []string{"p/a"}, 3},
+		{"exact pages", []string{"p/a", "p/b", "p/c", "p/d", "p/e", "p/f"}, 3},
+		{"partial page", []string{"p/1", "p/10", "p/2", "p/20", "p/3"}, 2},
+		{"prefix keys", []string{"p/", "p/a", "p/a/", "p/a/b", "p/b"}, 1},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s := New("test")
+			defer s.Destroy()
+			ctx := context.Background()
+			for _, key := range c.keys {
+				s.SetRaw(ctx, []byte(key), []byte(key), NeverExpire)
+			}
+			// keys outside the prefix on both sides must not leak into the pages
+			s.SetRaw(ctx, []byte("o"), []byte("o"), NeverExpire)
+			s.SetRaw(ctx, []byte("q"), []byte("q"), NeverExpire)
+
+			var got []string
+			var cursor []byte
+			for page := 0; page <= len(c.keys); page++ {
+				var keys []string
+				err := s.EnumerateRaw(ctx, []byte("p/"), cursor, 0, false, true, func(entry *store.RawEntry) bool {
+					// seek is inclusive, the cursor itself was emitted by the previous page
+					if cursor != nil && bytes.Equal(entry.Key, cursor) {
+						return true
+					}
+					keys = append(keys, string(entry.Key))
+					return len(keys) < c.pageSize
+				})
+				if err != nil {
+					t.Fatalf("EnumerateRaw: %v", err)
+				}
+				if len(keys) == 0 {
+					break
+				}
+				got = append(got, keys...)
+				cursor = []byte(keys[len(keys)-1])
+			}
+
+			want := append([]string{}, c.keys...)
+			sort.Sort(sort.Reverse(sort.StringSlice(want)))
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("reverse pages = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDropAllConcurrentSetRaw(t *testing.T) {
+
+	s := New("test", WithMaxEntries(64), WithValueSizeStats(), WithExpirationIndex())
+	defer s.Destroy()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := []byte(fmt.Sprintf("w%d/%d", w, i%32))
+				if err := s.SetRaw(ctx, key, key, 60); err != nil {
+					t.Errorf("SetRaw: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	for i := 0; i < 200; i++ {
+		if err := s.DropAll(); err != nil {
+			t.Fatalf("DropAll: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if err := s.DropAll(); err != nil {
+		t.Fatalf("DropAll: %v", err)
+	}
+	if n := s.cache.ItemCount(); n != 0 {
+		t.Errorf("%d entries left after DropAll", n)
+	}
+	s.SetRaw(ctx, []byte("after"), []byte("v"), 60)
+	if got, _ := s.GetRaw(ctx, []byte("after"), nil, nil, true); string(got) != "v" {
+		t.Errorf("GetRaw after DropAll = %q", got)
+	}
+}
+
+func TestTTLSentinels(t *testing.T) {
+
+	ops := map[string]func(s *cacheStore, key []byte, ttlSeconds int) error{
+		"SetRaw": func(s *cacheStore, key []byte, ttlSeconds int) error {
+			return s.SetRaw(context.Background(), key, []byte("v"), ttlSeconds)
+		},
+		"TouchRaw": func(s *cacheStore, key []byte, ttlSeconds int) error {
+			s.SetRaw(context.Background(), key, []byte("v"), 5)
+			return s.TouchRaw(context.Background(), key, ttlSeconds)
+		},
+		"UpdateRaw": func(s *cacheStore, key []byte, ttlSeconds int) error {
+			return s.UpdateRaw(context.Background(), key, func(entry *store.RawEntry) bool {
+				entry.Value = []byte("v")
+				entry.Ttl = ttlSeconds
+				return true
+			})
+		},
+		"IncrementRaw": func(s *cacheStore, key []byte, ttlSeconds int) error {
+			_, err := s.IncrementRaw(context.Background(), key, 0, 1, ttlSeconds)
+			return err
+		},
+	}
+
+	cases := []struct {
+		name       string
+		defaultTTL time.Duration
+		ttlSeconds int
+		want       int
+	}{
+		{"default ttl", 10 * time.Minute, UseDefaultTTL, 600},
+		{"default ttl unset", 0, UseDefaultTTL, store.NoTTL},
+		{"never expire", 10 * time.Minute, NeverExpire, store.NoTTL},
+		{"positive", 10 * time.Minute, 30, 30},
+	}
+
+	for name, op := range ops {
+		for _, c := range cases {
+			op, c := op, c
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				s := New("test", WithDefaultTTL(c.defaultTTL))
+				defer s.Destroy()
+				key := []byte("k")
+				if err := op(s, key, c.ttlSeconds); err != nil {
+					t.Fatalf("op: %v", err)
+				}
+				var ttl int
+				if _, err := s.GetRaw(context.Background(), key, &ttl, nil, true); err != nil {
+					t.Fatalf("GetRaw: %v", err)
+				}
+				if ttl != c.want && (c.want == store.NoTTL || ttl < c.want-1 || ttl > c.want) {
+					t.Errorf("ttl = %d, want %d", ttl, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestEnumerateRawDeleteDuringWalk(t *testing.T) {
+
+	for _, reverse := range []bool{false, true} {
+		for _, onlyKeys := range []bool{false, true} {
+			s := New("test")
+			ctx := context.Background()
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				s.SetRaw(ctx, []byte(key), []byte(key), NeverExpire)
+			}
+
+			// forward order is not defined, so the first emitted key decides which one is removed
+			var got []string
+			removed := "c"
+			err := s.EnumerateRaw(ctx, nil, nil, 0, onlyKeys, reverse, func(entry *store.RawEntry) bool {
+				if len(got) == 0 {
+					if string(entry.Key) == removed {
+						removed = "d"
+					}
+					s.RemoveRaw(ctx, []byte(removed))
+				}
+				if !onlyKeys && entry.Value == nil {
+					t.Errorf("reverse %v: nil value for %q", reverse, entry.Key)
+				}
+				got = append(got, string(entry.Key))
+				return true
+			})
+			s.Destroy()
+
+			var want []string
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				if key != removed {
+					want = append(want, key)
+				}
+			}
+			if reverse {
+				sort.Sort(sort.Reverse(sort.StringSlice(want)))
+			} else {
+				sort.Strings(got)
+			}
+			if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("reverse %v onlyKeys %v: got %v, %v, want %s", reverse, onlyKeys, got, err, want)
+			}
+		}
+	}
+}
+
+func TestTouchPrefixRawPendingAndContext(t *testing.T) {
+
+	s := New("test", WithWriteCoalescing(50*time.Millisecond))
+	defer s.Destroy()
+	ctx := context.Background()
+
+	s.SetRaw(ctx, []byte("p/a"), []byte("1"), 1)
+	s.SetRaw(ctx, []byte("p/b"), []byte("2"), 1)
+	if n, err := s.TouchPrefixRaw(ctx, []byte("p/"), 3600); err != nil || n != 2 {
+		t.Fatalf("TouchPrefixRaw = %d, %v, want 2", n, err)
+	}
+	// past the window the pending writes must not bring back their ttl
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"p/a", "p/b"} {
+		var ttl int
+		s.GetRaw(ctx, []byte(key), &ttl, nil, true)
+		if ttl < 3599 {
+			t.Errorf("%s: ttl = %d, want 3600", key, ttl)
+		}
+	}
+
+	// the wait for a key lock is bounded by the context
+	locked, release := make(chan struct{}), make(chan struct{})
+	go s.UpdateRaw(ctx, []byte("p/a"), func(entry *store.RawEntry) bool {
+		close(locked)
+		<-release
+		return false
+	})
+	<-locked
+	defer close(release)
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.TouchPrefixRaw(timeout, []byte("p/"), 60); err != context.DeadlineExceeded {
+		t.Fatalf("TouchPrefixRaw with locked key = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+const streamChunkSize = 32 * 1024
+
+// SetRawStream reads r fully and stores the result, the context is checked between chunks
+// and the read fails with ErrValueTooLarge past the configured MaxStreamSize
+func (t *cacheStore) SetRawStream(ctx context.Context, key []byte, r io.Reader, ttlSeconds int) error {
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	chunk := make([]byte, streamChunkSize)
+	limit := t.conf.MaxStreamSize
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.Read(chunk)
+		if n > 0 {
+			if limit > 0 && int64(buf.Len()+n) > limit {
+				return ErrValueTooLarge
+			}
+			buf.Write(chunk[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.SetRaw(ctx, key, buf.Bytes(), ttlSeconds)
+}
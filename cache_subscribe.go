@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"github.com/keyvalstore/store"
+	"sync"
+	"sync/atomic"
+)
+
+// capacity of the subscriber channel, events beyond it are dropped
+const subscriberBuffer = 16
+
+type subscriber struct {
+	ch chan store.RawEntry
+}
+
+type subscribers struct {
+	count int32
+	mu    sync.RWMutex
+	byKey map[string][]*subscriber
+}
+
+// Subscribe returns the channel receiving the new entry on each write of the key and the unsubscribe function,
+// removal sends the entry with nil value. Deliveries never block writers, events for a slow subscriber are dropped.
+// The key is checked like the key of a write, a key no write accepts fails instead of returning a silent channel.
+func (t *cacheStore) Subscribe(key []byte) (<-chan store.RawEntry, func(), error) {
+
+	if err := t.checkKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	s := &subscriber{ch: make(chan store.RawEntry, subscriberBuffer)}
+	k := string(key)
+
+	t.subs.mu.Lock()
+	if t.subs.byKey == nil {
+		t.subs.byKey = make(map[string][]*subscriber)
+	}
+	t.subs.byKey[k] = append(t.subs.byKey[k], s)
+	atomic.AddInt32(&t.subs.count, 1)
+	t.subs.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			t.subs.remove(k, s)
+		})
+	}, nil
+}
+
+func (t *subscribers) remove(key string, s *subscriber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.byKey[key]
+	for i, e := range list {
+		if e == s {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(t.byKey, key)
+	} else {
+		t.byKey[key] = list
+	}
+	atomic.AddInt32(&t.count, -1)
+	close(s.ch)
+}
+
+// notify is called by writers under the key lock with the key in the cache
+func (t *cacheStore) notify(key, value []byte, ttlSeconds int) {
+	if atomic.LoadInt32(&t.subs.count) == 0 {
+		return
+	}
+	key = t.userRawKey(key)
+	t.subs.mu.RLock()
+	defer t.subs.mu.RUnlock()
+	list := t.subs.byKey[string(key)]
+	if len(list) == 0 {
+		return
+	}
+	key = append([]byte(nil), key...)
+	for _, s := range list {
+		select {
+		case s.ch <- store.RawEntry{Key: key, Value: value, Ttl: ttlSeconds}:
+		default:
+			t.stats.drop()
+		}
+	}
+}
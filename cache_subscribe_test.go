@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeChecksKey(t *testing.T) {
+
+	s := New("test", WithRejectEmptyKeys(), WithMaxKeyLength(4))
+	defer s.Destroy()
+
+	for key, want := range map[string]error{"": ErrEmptyKey, "toolong": ErrKeyTooLong} {
+		if ch, _, err := s.Subscribe([]byte(key)); err != want || ch != nil {
+			t.Errorf("Subscribe(%q) = %v, want %v", key, err, want)
+		}
+	}
+
+	ch, unsubscribe, err := s.Subscribe([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	s.SetRaw(context.Background(), []byte("k"), []byte("v"), NeverExpire)
+	select {
+	case entry := <-ch:
+		if string(entry.Key) != "k" || string(entry.Value) != "v" {
+			t.Errorf("event = %q %q", entry.Key, entry.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event for the write")
+	}
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"log"
+	"sync"
+	"time"
+)
+
+// sweeper is the store owned replacement of the go-cache janitor
+type sweeper struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func (t *cacheStore) runSweeper(interval time.Duration) {
+	t.sweeper.start(interval, t.SweepExpired)
+}
+
+// start runs work every interval in the goroutine of the sweeper, a slow run makes the ticker drop ticks, so runs never overlap
+func (t *sweeper) start(interval time.Duration, work func()) {
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				work()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (t *cacheStore) stopSweeper() {
+	t.sweeper.halt()
+	t.compactor.halt()
+	t.idler.halt()
+	t.pressure.halt()
+	t.verifier.halt()
+}
+
+func (t *sweeper) halt() {
+	t.once.Do(func() {
+		if t.stop != nil {
+			close(t.stop)
+		}
+	})
+}
+
+// waitSweeper waits for the sweeps and the compaction in progress to finish after stopSweeper
+func (t *cacheStore) waitSweeper(ctx context.Context) error {
+	for _, s := range []*sweeper{&t.sweeper, &t.compactor, &t.idler, &t.pressure, &t.verifier} {
+		if err := s.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sweeper) wait(ctx context.Context) error {
+	if t.done == nil {
+		return nil
+	}
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SweepExpired reaps expired entries consulting the expiration policy if configured
+func (t *cacheStore) SweepExpired() {
+	if t.conf.ExpirationIndex {
+		t.sweepIndex()
+	} else {
+		t.cache.DeleteExpired()
+	}
+	if t.conf.ExpiredGrace > 0 {
+		t.tombs.purge(time.Now().UnixNano())
+	}
+	if t.conf.SoftDeleteGrace > 0 {
+		t.deletes.purge(time.Now().UnixNano())
+	}
+}
+
+// explicit deletes are marked so the eviction callback can tell them from expiration,
+// reaps by the expiration index are marked so the callback knows the key lock is already held
+type deleteMarks struct {
+	mu   sync.Mutex
+	keys map[string]int
+}
+
+func (t *deleteMarks) mark(key string) {
+	t.mu.Lock()
+	if t.keys == nil {
+		t.keys = make(map[string]int)
+	}
+	t.keys[key]++
+	t.mu.Unlock()
+}
+
+func (t *deleteMarks) unmark(key string) {
+	t.mu.Lock()
+	if t.keys[key]--; t.keys[key] <= 0 {
+		delete(t.keys, key)
+	}
+	t.mu.Unlock()
+}
+
+func (t *deleteMarks) has(key string) bool {
+	t.mu.Lock()
+	_, ok := t.keys[key]
+	t.mu.Unlock()
+	return ok
+}
+
+// the eviction callback needs to tell explicit deletes from expiration
+func (t *cacheStore) tracksExpiration() bool {
+	return t.conf.ExpirationPolicy != nil || t.conf.ExpiredGrace > 0
+}
+
+// deleteKey must be used by the store for every explicit delete
+func (t *cacheStore) deleteKey(key string) {
+	if !t.tracksExpiration() {
+		t.cache.Delete(key)
+		return
+	}
+	t.deleting.mark(key)
+	t.cache.Delete(key)
+	t.deleting.unmark(key)
+	if t.conf.ExpiredGrace > 0 {
+		t.tombs.clear(key)
+	}
+}
+
+// expired is called for the entry reaped by expiration, either re-arms it by the policy or leaves the tombstone
+func (t *cacheStore) expired(key string, obj interface{}) {
+	if t.conf.ExpirationPolicy != nil && t.rearm(key, obj) {
+		return
+	}
+	if t.conf.ExpiredGrace > 0 {
+		t.tombs.add(key, time.Now().Add(t.conf.ExpiredGrace).UnixNano())
+	}
+}
+
+// rearm consults the expiration policy for the reaped entry and puts it back if the policy keeps it
+func (t *cacheStore) rearm(key string, obj interface{}) (kept bool) {
+
+	e, ok := toEntry(obj)
+	if !ok {
+		return false
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			log.Printf("cachestore '%s': expiration policy of key '%s' failed, %v", t.name, key, err)
+		}
+	}()
+	defer t.recoverCallback(&err)
+
+	keep, newTTL := t.conf.ExpirationPolicy([]byte(t.userKey(key)), e.Value)
+	if !keep {
+		return false
+	}
+	if newTTL <= 0 {
+		newTTL = cache.NoExpiration
+	}
+
+	if t.reaping.has(key) {
+		t.rearmLocked(key, e, newTTL)
+		return true
+	}
+
+	// the sweep may run nested in the callback of a write holding the lock,
+	// then the entry is put back once the writer is done, unless it wrote the key meanwhile
+	unlock, ok := t.locks.tryLockKey([]byte(key))
+	if !ok {
+		go func() {
+			unlock := t.locks.lockKey([]byte(key))
+			defer unlock()
+			t.rearmLocked(key, e, newTTL)
+		}()
+		return true
+	}
+	defer unlock()
+	t.rearmLocked(key, e, newTTL)
+	return true
+}
+
+// rearmLocked is called under the key lock
+func (t *cacheStore) rearmLocked(key string, e *entry, ttl time.Duration) {
+	if _, ok := t.cache.Get(key); !ok {
+		t.setItem(key, e, ttl)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDroppedStoreStopsSweeper(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 200; i++ {
+		s := New("test", WithCleanupInterval(time.Minute), WithIdleEviction(time.Minute))
+		s.SetRaw(context.Background(), []byte("a"), []byte("1"), NeverExpire)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		if n := runtime.NumGoroutine(); n <= before+10 {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running by dropped stores", n-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// with the expiration index a sweep with nothing due costs the same for any entry count,
+// without it go-cache walks every entry
+func BenchmarkSweepExpired(b *testing.B) {
+
+	for _, index := range []bool{false, true} {
+		for _, n := range []int{1000, 10000, 100000} {
+			name := fmt.Sprintf("scan/%d", n)
+			options := []Option{}
+			if index {
+				name = fmt.Sprintf("index/%d", n)
+				options = append(options, WithExpirationIndex())
+			}
+			b.Run(name, func(b *testing.B) {
+				s := New("bench", options...)
+				defer s.Destroy()
+				for i := 0; i < n; i++ {
+					s.SetRaw(context.Background(), []byte(strconv.Itoa(i)), []byte("v"), 3600)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					s.SweepExpired()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"sync"
+	"sync/atomic"
+)
+
+// tag index keeps tag -> keys and key -> tags references,
+// lock order is key lock first and then the index mutex
+type tagIndex struct {
+	active int32
+	mu     sync.Mutex
+	byTag  map[string]map[string]struct{}
+	byKey  map[string][]string
+}
+
+func (t *tagIndex) isActive() bool {
+	return atomic.LoadInt32(&t.active) == 1
+}
+
+func (t *tagIndex) tagLocked(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if t.byTag == nil {
+		t.byTag = make(map[string]map[string]struct{})
+		t.byKey = make(map[string][]string)
+		atomic.StoreInt32(&t.active, 1)
+	}
+	for _, tag := range tags {
+		keys, ok := t.byTag[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			t.byTag[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.byKey[key] = append(t.byKey[key], tags...)
+}
+
+func (t *tagIndex) untagLocked(key string) {
+	for _, tag := range t.byKey[key] {
+		if keys, ok := t.byTag[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(t.byTag, tag)
+			}
+		}
+	}
+	delete(t.byKey, key)
+}
+
+func (t *tagIndex) untag(key string) {
+	t.mu.Lock()
+	t.untagLocked(key)
+	t.mu.Unlock()
+}
+
+func (t *tagIndex) keys(tag string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var list []string
+	for key := range t.byTag[tag] {
+		list = append(list, key)
+	}
+	return list
+}
+
+func (t *tagIndex) reset() {
+	t.mu.Lock()
+	if t.byTag != nil {
+		t.byTag = make(map[string]map[string]struct{})
+		t.byKey = make(map[string][]string)
+	}
+	t.mu.Unlock()
+}
+
+// evicted drops references of the deleted or expired key unless it was written again in the meantime
+func (t *tagIndex) evicted(key string, c *cache.Cache) {
+	if !t.isActive() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := c.Get(key); !ok {
+		t.untagLocked(key)
+	}
+}
+
+// SetRawTagged sets the value and associates it with the tags replacing any previous ones
+func (t *cacheStore) SetRawTagged(ctx context.Context, key, value []byte, ttlSeconds int, tags ...string) error {
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ttl := t.expiration(ttlSeconds)
+
+	t.tags.mu.Lock()
+	defer t.tags.mu.Unlock()
+
+	t.setItem(string(key), t.newEntry(value), ttl)
+	t.tags.untagLocked(string(key))
+	t.tags.tagLocked(string(key), tags)
+	t.stats.set()
+	t.notify(key, value, ttlSeconds)
+	return nil
+}
+
+// InvalidateTag removes all keys carrying the tag and returns the number of removed entries,
+// the index holds the keys in the cache, so they are removed as they are without the namespace applied again
+func (t *cacheStore) InvalidateTag(tag string) int {
+
+	cnt := 0
+	for _, key := range t.tags.keys(tag) {
+		if existed, _ := t.removeExisted(context.Background(), []byte(key)); existed {
+			cnt++
+		}
+		t.tags.untag(key)
+	}
+
+	return cnt
+}
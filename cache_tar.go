@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tarVersion    = "VERSION"
+	tarManifest   = "manifest.json"
+	tarDataPrefix = "data/"
+)
+
+type tarManifestEntry struct {
+	File        string `json:"file"`
+	Ttl         int    `json:"ttl"`
+	Version     int64  `json:"version"`
+	ContentType string `json:"content_type,omitempty"`
+	Created     int64  `json:"created,omitempty"`
+}
+
+// ExportTar writes live entries as a gzip tar for offline inspection, the VERSION file with the format version goes first,
+// the manifest follows and
+// lists ttl in remaining seconds, version, content type and creation time in unix nanoseconds of every entry, values are stored in data/ files named by the path escaped key
+func (t *cacheStore) ExportTar(w io.Writer) error {
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	now := time.Now()
+	var manifest []tarManifestEntry
+	values := make(map[string][]byte)
+
+	for key, item := range t.items() {
+		e, ok := toEntry(item.Object)
+		if !ok {
+			continue
+		}
+		file := tarDataPrefix + url.PathEscape(t.userKey(key))
+		manifest = append(manifest, tarManifestEntry{
+			File:        file,
+			Ttl:         remainingSeconds(item.Expiration, now.UnixNano()),
+			Version:     e.Version,
+			ContentType: e.ContentType,
+			Created:     e.Created,
+		})
+		values[file] = e.Value
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	if err := writeTarFile(tw, tarVersion, []byte(strconv.Itoa(backupVersion)), now); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, tarManifest, data, now); err != nil {
+		return err
+	}
+	for _, m := range manifest {
+		if err := writeTarFile(tw, m.File, values[m.File], now); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ImportTar reads entries written by ExportTar, ttl is counted from the import time with zero meaning NeverExpire,
+// new versions are assigned, archives without the supported VERSION file first fail with ErrUnsupportedBackupVersion
+func (t *cacheStore) ImportTar(r io.Reader) error {
+
+	t.backupMu.Lock()
+	defer t.backupMu.Unlock()
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	ctx := context.Background()
+	var entries map[string]tarManifestEntry
+
+	hdr, err := tr.Next()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF || hdr.Name != tarVersion {
+		return ErrUnsupportedBackupVersion
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return err
+	}
+	if version, err := strconv.Atoi(strings.TrimSpace(string(data))); err != nil || !supportedBackupVersion(version) {
+		return ErrUnsupportedBackupVersion
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if hdr.Name == tarManifest {
+			var manifest []tarManifestEntry
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return fmt.Errorf("invalid manifest, %v", err)
+			}
+			entries = make(map[string]tarManifestEntry, len(manifest))
+			for _, m := range manifest {
+				if m.Ttl == 0 {
+					m.Ttl = NeverExpire
+				}
+				entries[m.File] = m
+			}
+			continue
+		}
+
+		if entries == nil {
+			return errors.New("manifest must precede data files")
+		}
+		if !strings.HasPrefix(hdr.Name, tarDataPrefix) {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimPrefix(hdr.Name, tarDataPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid file name '%s', %v", hdr.Name, err)
+		}
+		m := entries[hdr.Name]
+		if err := t.setRawMeta(ctx, []byte(key), data, m.ContentType, m.Created, m.Ttl); err != nil {
+			return err
+		}
+	}
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"time"
+)
+
+// Freshness is the state of the entry written by SetRawTiered as reported by GetRawTiered
+type Freshness int
+
+const (
+	// the entry is absent or past its max stale period
+	Dead Freshness = iota
+	// the entry is within its fresh period, entries written without tiers are always fresh
+	Fresh
+	// the entry is past its fresh period and can be served while it is revalidated
+	Stale
+)
+
+func (f Freshness) String() string {
+	switch f {
+	case Fresh:
+		return "fresh"
+	case Stale:
+		return "stale"
+	default:
+		return "dead"
+	}
+}
+
+// SetRawTiered stores the value fresh for freshSeconds and stale for maxStaleSeconds more, after that the entry expires,
+// the total ttl is subject to the configured bounds like any other ttl
+func (t *cacheStore) SetRawTiered(ctx context.Context, key, value []byte, freshSeconds, maxStaleSeconds int) error {
+
+	if freshSeconds <= 0 || maxStaleSeconds < 0 {
+		return ErrInvalidTiers
+	}
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.newEntry(value)
+	e.FreshUntil = time.Now().Add(time.Duration(freshSeconds) * time.Second).UnixNano()
+	t.putLocked(key, e, freshSeconds+maxStaleSeconds)
+	return nil
+}
+
+// GetRawTiered returns the value with its freshness, nil value and Dead for the missing key
+func (t *cacheStore) GetRawTiered(ctx context.Context, key []byte) ([]byte, Freshness, error) {
+	e, err := t.getRawEntry(ctx, key, false)
+	if e == nil {
+		return nil, Dead, err
+	}
+	if e.FreshUntil != 0 && time.Now().UnixNano() >= e.FreshUntil {
+		return e.Value, Stale, nil
+	}
+	return e.Value, Fresh, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"sync"
+	"time"
+)
+
+// tombstones remember keys reaped by expiration until their grace deadline
+type tombstones struct {
+	mu   sync.Mutex
+	keys map[string]int64
+}
+
+func (t *tombstones) add(key string, deadline int64) {
+	t.mu.Lock()
+	if t.keys == nil {
+		t.keys = make(map[string]int64)
+	}
+	t.keys[key] = deadline
+	t.mu.Unlock()
+}
+
+func (t *tombstones) clear(key string) {
+	t.mu.Lock()
+	delete(t.keys, key)
+	t.mu.Unlock()
+}
+
+func (t *tombstones) has(key string, now int64) bool {
+	t.mu.Lock()
+	deadline, ok := t.keys[key]
+	t.mu.Unlock()
+	return ok && now <= deadline
+}
+
+// purge drops tombstones past their grace deadline
+func (t *tombstones) purge(now int64) {
+	t.mu.Lock()
+	for key, deadline := range t.keys {
+		if now > deadline {
+			delete(t.keys, key)
+		}
+	}
+	t.mu.Unlock()
+}
+
+func (t *tombstones) reset() {
+	t.mu.Lock()
+	t.keys = nil
+	t.mu.Unlock()
+}
+
+// isExpired reports whether the missing key was reaped by expiration within the grace period
+func (t *cacheStore) isExpired(key []byte) bool {
+	return t.conf.ExpiredGrace > 0 && t.tombs.has(string(key), time.Now().UnixNano())
+}
+
+// deletion is the soft delete marker kept for EnumerateSince
+type deletion struct {
+	version  int64
+	deadline int64
+}
+
+// deletions remember keys removed by RemoveRaw, RemoveRawExisted and PopRaw until their grace deadline
+type deletions struct {
+	mu   sync.Mutex
+	keys map[string]deletion
+}
+
+func (t *deletions) add(key string, d deletion) {
+	t.mu.Lock()
+	if t.keys == nil {
+		t.keys = make(map[string]deletion)
+	}
+	t.keys[key] = d
+	t.mu.Unlock()
+}
+
+// since returns the markers newer than the version and still within grace
+func (t *deletions) since(version, now int64) map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make(map[string]int64)
+	for key, d := range t.keys {
+		if d.version > version && now <= d.deadline {
+			list[key] = d.version
+		}
+	}
+	return list
+}
+
+func (t *deletions) purge(now int64) {
+	t.mu.Lock()
+	for key, d := range t.keys {
+		if now > d.deadline {
+			delete(t.keys, key)
+		}
+	}
+	t.mu.Unlock()
+}
+
+func (t *deletions) reset() {
+	t.mu.Lock()
+	t.keys = nil
+	t.mu.Unlock()
+}
+
+// softDeleted records the explicit removal of the key under its lock, the marker takes the next version
+func (t *cacheStore) softDeleted(key string) {
+	if t.conf.SoftDeleteGrace > 0 {
+		t.deletes.add(key, deletion{
+			version:  t.nextVersion(),
+			deadline: time.Now().Add(t.conf.SoftDeleteGrace).UnixNano(),
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"context"
+	"github.com/keyvalstore/store"
+)
+
+// SetRawTyped stores the value together with its content type, for example "application/json"
+func (t *cacheStore) SetRawTyped(ctx context.Context, key, value []byte, contentType string, ttlSeconds int) error {
+	return t.setRawMeta(ctx, key, value, contentType, 0, ttlSeconds)
+}
+
+// setRawMeta is SetRaw keeping the metadata of the entry, zero created means now
+func (t *cacheStore) setRawMeta(ctx context.Context, key, value []byte, contentType string, created int64, ttlSeconds int) error {
+
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	key = t.nsKey(key)
+
+	if err := t.validate(key, value); err != nil {
+		return err
+	}
+
+	if err := t.enterWrite(); err != nil {
+		return err
+	}
+	defer t.exitWrite()
+
+	defer t.evictOverflow(key)
+
+	unlock, err := t.lockKeyContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.newEntry(value)
+	e.ContentType = contentType
+	if created != 0 {
+		e.Created = created
+	}
+	t.putLocked(key, e, ttlSeconds)
+	return nil
+}
+
+// GetRawTyped returns the value and its content type, DefaultContentType for values written without one, os.ErrNotExist if absent
+func (t *cacheStore) GetRawTyped(ctx context.Context, key []byte) (value []byte, contentType string, err error) {
+	e, err := t.getRawEntry(ctx, key, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return e.Value, t.contentType(e), nil
+}
+
+// contentType of the entry written without one is DefaultContentType, applied on read so entries do not carry it
+func (t *cacheStore) contentType(e *entry) string {
+	if e.ContentType == "" {
+		return t.conf.DefaultContentType
+	}
+	return e.ContentType
+}
+
+// EnumerateTypedRaw is the forward EnumerateRaw that also passes the content type of every entry
+func (t *cacheStore) EnumerateTypedRaw(ctx context.Context, prefix, seek []byte, cb func(entry *store.RawEntry, contentType string) bool) (err error) {
+	return t.EnumerateRawMeta(ctx, prefix, seek, false, false, func(entry *store.RawEntry, meta EntryMeta) bool {
+		return cb(entry, meta.ContentType)
+	})
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import "context"
+
+// TypedStore stores values of type T through the codec on top of the raw methods
+type TypedStore[T any] struct {
+	t     *cacheStore
+	codec Codec
+}
+
+// NewTypedStore wraps the store, nil codec means the codec of the store or JSON if the store has none
+func NewTypedStore[T any](s *cacheStore, codec Codec) *TypedStore[T] {
+	if codec == nil {
+		codec = s.conf.Codec
+	}
+	if codec == nil {
+		codec = jsonCodec{}
+	}
+	return &TypedStore[T]{t: s, codec: codec}
+}
+
+func (ts *TypedStore[T]) Set(ctx context.Context, key string, v T, ttlSeconds int) error {
+	value, err := ts.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ts.t.SetRaw(ctx, []byte(key), value, ttlSeconds)
+}
+
+// Get returns false with the zero value if the key is absent
+func (ts *TypedStore[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var v T
+	value, err := ts.t.GetRaw(ctx, []byte(key), nil, nil, false)
+	if err != nil || value == nil {
+		return v, false, err
+	}
+	if err := ts.codec.Unmarshal(value, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
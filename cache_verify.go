@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+package cachestore
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+func (t *cacheStore) runVerifier(interval time.Duration) {
+	t.verifier.start(interval, func() {
+		if err := t.Verify(); err != nil {
+			log.Printf("cachestore '%s': %v", t.name, err)
+		}
+	})
+}
+
+// Verify cross-checks the auxiliary structures of the enabled features against the cache while the store is frozen:
+// the value size accounting, the expiration index, the prefix bloom filter and the tag index.
+// Returns the error wrapping ErrInconsistent that describes the first mismatch. Entries expiring during the check
+// are not reported, writes made bypassing the store through Instance are. Must not be called from the callback of a mutating operation
+func (t *cacheStore) Verify() error {
+
+	t.Freeze()
+	defer t.Unfreeze()
+
+	items := t.items()
+	// keys reaped while the check runs are not mismatches
+	gone := func(key string) bool {
+		_, ok := t.cache.Get(key)
+		return !ok
+	}
+
+	if t.conf.ValueSizeStats {
+		t.sizes.mu.Lock()
+		counted := t.sizes.total
+		sizes := make(map[string]int, len(t.sizes.sizes))
+		var total int64
+		for key, size := range t.sizes.sizes {
+			sizes[key] = size
+			total += int64(size)
+		}
+		t.sizes.mu.Unlock()
+		if total != counted {
+			return fmt.Errorf("%w, value size total %d differs from the sum %d of tracked sizes", ErrInconsistent, counted, total)
+		}
+		if n := t.cache.ItemCount(); len(sizes) > n {
+			return fmt.Errorf("%w, value sizes track %d keys while the cache holds %d", ErrInconsistent, len(sizes), n)
+		}
+		for key, item := range items {
+			e, ok := toEntry(item.Object)
+			if !ok {
+				continue
+			}
+			if size, ok := sizes[key]; (!ok || size != len(e.Value)) && !gone(key) {
+				return fmt.Errorf("%w, tracked size %d of key '%s' differs from the value size %d", ErrInconsistent, size, t.userKey(key), len(e.Value))
+			}
+		}
+	}
+
+	if t.conf.ExpirationIndex {
+		t.expIndex.mu.Lock()
+		indexed := make(map[string]int64, len(t.expIndex.items))
+		for _, it := range t.expIndex.items {
+			if it.at > indexed[it.key] {
+				indexed[it.key] = it.at
+			}
+		}
+		t.expIndex.mu.Unlock()
+		for key, item := range items {
+			if item.Expiration > 0 && indexed[key] < item.Expiration && !gone(key) {
+				return fmt.Errorf("%w, expiration of key '%s' is missing from the expiration index", ErrInconsistent, t.userKey(key))
+			}
+		}
+	}
+
+	if t.bloom.enabled() {
+		for key := range items {
+			userKey := t.userKey(key)
+			for _, i := range t.bloom.slots(t.bloom.prefixOf(userKey)) {
+				if atomic.LoadUint32(&t.bloom.counters[i]) == 0 && !gone(key) {
+					return fmt.Errorf("%w, prefix bloom filter misses key '%s'", ErrInconsistent, userKey)
+				}
+			}
+		}
+	}
+
+	if t.tags.isActive() {
+		t.tags.mu.Lock()
+		defer t.tags.mu.Unlock()
+		for tag, keys := range t.tags.byTag {
+			for key := range keys {
+				if !containsString(t.tags.byKey[key], tag) {
+					return fmt.Errorf("%w, tag '%s' lists key '%s' that does not carry it", ErrInconsistent, tag, t.userKey(key))
+				}
+			}
+		}
+		for key, tags := range t.tags.byKey {
+			for _, tag := range tags {
+				if _, ok := t.tags.byTag[tag][key]; !ok {
+					return fmt.Errorf("%w, key '%s' carries tag '%s' that does not list it", ErrInconsistent, t.userKey(key), tag)
+				}
+			}
+		}
+		if n := t.cache.ItemCount(); len(t.tags.byKey) > n {
+			return fmt.Errorf("%w, tag index tracks %d keys while the cache holds %d", ErrInconsistent, len(t.tags.byKey), n)
+		}
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
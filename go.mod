@@ -1,15 +1,15 @@
 module github.com/keyvalstore/cachestore
 
-go 1.17
+go 1.18
 
 require (
 	github.com/keyvalstore/store v1.3.1
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	google.golang.org/protobuf v1.28.1
 )
 
 require (
 	github.com/codeallergy/glue v1.1.4 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
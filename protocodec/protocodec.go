@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2023 Zander Schwid & Co. LLC.
+ * SPDX-License-Identifier: BUSL-1.1
+ */
+
+// Package protocodec stores protobuf messages in cachestore, it lives apart so the core package
+// does not depend on protobuf
+package protocodec
+
+import (
+	"context"
+	"errors"
+	"github.com/keyvalstore/cachestore"
+	"github.com/keyvalstore/store"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// Codec implements cachestore.Codec for proto.Message objects
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(msg)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// WithProtoCodec makes SetObject and GetObject of the store use protobuf
+func WithProtoCodec() cachestore.Option {
+	return cachestore.WithCodec(Codec{})
+}
+
+// SetProto marshals the message and stores it in any data store
+func SetProto(ctx context.Context, ds store.DataStore, key []byte, msg proto.Message, ttlSeconds int) error {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return ds.SetRaw(ctx, key, value, ttlSeconds)
+}
+
+// GetProto unmarshals the stored value into msg, returns os.ErrNotExist if absent
+func GetProto(ctx context.Context, ds store.DataStore, key []byte, msg proto.Message) error {
+	value, err := ds.GetRaw(ctx, key, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(value, msg)
+}